@@ -2,22 +2,21 @@ package collections
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"time"
 
-	rtime "github.com/r2day/base/time"
-	"github.com/r2day/db"
+	cerrors "github.com/r2day/collections/errors"
 	"github.com/r2day/rest"
 	log "github.com/sirupsen/logrus"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const (
 	// ManagerAccountCollection 管理员账号表-名称
+	// 已经被 auth/account 的账号表取代，仅用于兼容以及迁移
 	ManagerAccountCollection = "sys_manage_account"
 )
 
@@ -29,14 +28,19 @@ const (
 )
 
 // ManagerAccountModel 管理员账号
+//
+// Deprecated: 使用 auth/account.Model，字段相同并且支持访问范围、版本号以及校验规则。
+// 通过 account.FromManagerAccount 以及 Model.ManagerAccount 相互转换，
+// 旧表中的记录通过 account.Model.MigrateManagerAccounts 迁移到账号表。
+// 默认读写旧表，迁移之后通过 SetManagerAccountStore 改为读写账号表
 type ManagerAccountModel struct {
 	// 创建时（用户上传的数据为空，所以默认可以不传该值)
 	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 
 	// 创建时间
-	CreatedAt string `json:"created_at" bson:"created_at"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
 	// 更新时间
-	UpdatedAt string `json:"updated_at" bson:"updated_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
 	// 是否开启审核
 	IsRequiredApprove bool `json:"is_required_approve" bson:"is_required_approve"`
 	// 状态
@@ -55,7 +59,8 @@ type ManagerAccountModel struct {
 	// 手机号
 	Phone string `json:"phone"`
 	// 密码
-	Password string `json:"password"  bson:"password"`
+	// 保存的是 bcrypt 哈希值，返回的 JSON 中不包含该字段
+	Password string `json:"password,omitempty"  bson:"password"`
 
 	// 更多信息
 	// 账号名称
@@ -78,234 +83,168 @@ func (m *universalModel) ResourceName() string {
 	return "account"
 }
 
-// SimpleSave 快速保存
-func (m *universalModel) SimpleSave(ctx context.Context) error {
-	// TODO result using custom struct instead of bson.M
-	// because you should avoid to export something to customers
-	coll := db.MDB.Collection(ManagerAccountCollection)
+// ManagerAccountStore 管理员账号的存储
+// 默认读写旧表 sys_manage_account，迁移之后可以通过 SetManagerAccountStore 改为账号表
+type ManagerAccountStore interface {
+	// Create 创建账号，成功后设置 m.ID
+	Create(ctx context.Context, m *ManagerAccountModel) error
+	// UpdateByID 通过 m.ID 整体更新账号
+	UpdateByID(ctx context.Context, m *ManagerAccountModel) error
+	// Update 整体更新访问范围内的账号
+	Update(ctx context.Context, scope Scope, id string, m *ManagerAccountModel) error
+	// Delete 删除访问范围内的账号
+	Delete(ctx context.Context, scope Scope, id string) error
+	// FindOne 通过过滤条件查找一个账号
+	FindOne(ctx context.Context, filter bson.D) (*ManagerAccountModel, error)
+	// GetOne 获取访问范围内的账号
+	GetOne(ctx context.Context, scope Scope, id string) (*ManagerAccountModel, error)
+	// GetMany 获取访问范围内指定id的账号
+	GetMany(ctx context.Context, scope Scope, ids []string) ([]*ManagerAccountModel, error)
+	// GetList 获取访问范围内的账号列表
+	GetList(ctx context.Context, scope Scope, urlParams *rest.UrlParams) ([]*ManagerAccountModel, int64, error)
+	// SetPassword 保存已经计算好的密码哈希值
+	SetPassword(ctx context.Context, id primitive.ObjectID, hash string) error
+}
 
-	// 保存时间设定
-	m.CreatedAt = rtime.FomratTimeAsReader(time.Now().Unix())
-	m.UpdatedAt = rtime.FomratTimeAsReader(time.Now().Unix())
+// managerAccountStore 管理员账号的存储
+var managerAccountStore ManagerAccountStore = legacyManagerAccounts{}
 
-	// 插入记录
-	_, err := coll.InsertOne(ctx, m)
-	if err != nil {
-		return err
+// SetManagerAccountStore 设置管理员账号的存储
+// 一般在迁移之后调用，例如 collections.SetManagerAccountStore(account.ManagerAccountStore(account.Repo))，
+// 之后 ManagerAccountModel 与 account.Model 读写的是同一份数据；为空时恢复为旧表
+func SetManagerAccountStore(store ManagerAccountStore) {
+	if store == nil {
+		store = legacyManagerAccounts{}
 	}
-	return nil
+	managerAccountStore = store
 }
 
-// UpdateById 通过id更新数据库
-func (m *universalModel) UpdateById(ctx context.Context) error {
-	coll := db.MDB.Collection(ManagerAccountCollection)
-	// 更新数据库
-	m.UpdatedAt = rtime.FomratTimeAsReader(time.Now().Unix())
-	filter := bson.D{{Key: "_id", Value: m.ID}}
-	_, err := coll.UpdateOne(ctx, filter,
-		bson.D{{Key: "$set", Value: m}})
-	if err != nil {
-		return err
+// managerAccounts 返回管理员账号的存储
+func managerAccounts() ManagerAccountStore {
+	return managerAccountStore
+}
+
+// isLegacyStore 判断是否是旧表
+func isLegacyStore(store ManagerAccountStore) bool {
+	_, ok := store.(legacyManagerAccounts)
+	return ok
+}
+
+// SimpleSave 快速保存
+// 与 account.Repo.Create 相同，需要 context 中有操作者的访问范围；没有账号id时使用记录的id
+func (m *universalModel) SimpleSave(ctx context.Context) error {
+	store := managerAccounts()
+	if m.ID.IsZero() {
+		m.ID = primitive.NewObjectID()
 	}
-	return nil
+	if m.AccountId == "" {
+		m.AccountId = m.ID.Hex()
+	}
+	return store.Create(ctx, m)
+}
+
+// UpdateById 通过id整体更新数据库
+// 未设置的字段会被置为零值，密码为空时保持不变
+func (m *universalModel) UpdateById(ctx context.Context) error {
+	store := managerAccounts()
+	return store.UpdateByID(ctx, m)
 }
 
 // FindByPhone 通过手机号查找到账号信息
 func (m *universalModel) FindByPhone(ctx context.Context) error {
-	coll := db.MDB.Collection(ManagerAccountCollection)
-	// 更新数据库
-	filter := bson.D{{Key: "phone", Value: m.Phone}}
-	err := coll.FindOne(ctx, filter).Decode(m)
-	if err != nil {
-		return err
-	}
-	return nil
+	return m.findOne(ctx, bson.D{{Key: "phone", Value: m.Phone}})
 }
 
-// FindByAccountId 通过手机号查找到账号信息
+// FindByAccountId 通过账号id查找到账号信息
 func (m *universalModel) FindByAccountId(ctx context.Context) error {
-	coll := db.MDB.Collection(ManagerAccountCollection)
-	// 更新数据库
-	filter := bson.D{{Key: "account_id", Value: m.AccountId}}
-	err := coll.FindOne(ctx, filter).Decode(m)
-	if err != nil {
-		return err
-	}
-	return nil
+	return m.findOne(ctx, bson.D{{Key: "account_id", Value: m.AccountId}})
 }
 
-// Delete 快速删除
-func (m *universalModel) Delete(ctx context.Context, id string) error {
-	// TODO result using custom struct instead of bson.M
-	// because you should avoid to export something to customers
-	coll := db.MDB.Collection(ManagerAccountCollection)
-	objId, _ := primitive.ObjectIDFromHex(id)
-	filter := bson.D{{Key: "_id", Value: objId}}
-
-	// 执行删除
-	result, err := coll.DeleteOne(ctx, filter)
-
-	if err != nil {
-		log.WithField("id", id).Error(err)
-		return err
+// findOne 通过过滤条件查找账号并保存到 m 中
+// 使用账号表时，还没有迁移的账号从旧表中查找，以便迁移之前可以继续登录
+func (m *universalModel) findOne(ctx context.Context, filter bson.D) error {
+	store := managerAccounts()
+	result, err := store.FindOne(ctx, filter)
+	if errors.Is(err, cerrors.ErrNotFound) && !isLegacyStore(store) {
+		result, err = legacyManagerAccounts{}.FindOne(ctx, filter)
 	}
-
-	if result.DeletedCount < 1 {
-		log.WithField("id", id).Error("delete failed")
+	if err != nil {
 		return err
 	}
-
+	*m = *result
 	return nil
 }
 
-func (m *universalModel) List(ctx context.Context, merchantID string, urlParams *rest.UrlParams) ([]*universalModel, int64, error) {
-	coll := db.MDB.Collection(ManagerAccountCollection)
-	// 声明需要返回的列表
-	results := make([]*universalModel, 0)
-	// 声明日志基本信息
-	logCtx := log.WithField("merchantID", merchantID).WithField("urlParams.FilterMap", urlParams.FilterMap)
-	// 声明数据库过滤器
-	// 定义基本过滤规则
-	// 以商户id为基本命名空间
-	filters := bson.D{{Key: "merchant_id", Value: merchantID}}
-
-	// 添加更多过滤器
-	// 根据用户规则进行筛选
-	for key, val := range urlParams.FilterMap {
-		// 判断是否是通过id查询
-		// 则进行转换
-		// 一般对应于 ReferenceArrayInput 和 ReferenceManyField
-		if m.ResourceName() == key || key == "id" {
-			// string to array
-			results, err := m.GetManyInIds(ctx, val)
-			if err != nil {
-				logCtx.Error(err)
-				return nil, 0, err
-			}
-			logCtx.WithField("results", results).Warning("is reference request")
-			return results, int64(len(results)), nil
-		} else {
-			bm := bson.E{Key: key, Value: val}
-			filters = append(filters, bm)
-		}
-	}
-
-	// 添加状态过滤器
-	if urlParams.HasFilter {
-		filterByStatus := bson.E{Key: "status", Value: urlParams.FilterCommon.Status}
-		filters = append(filters, filterByStatus)
-	}
-
-	logCtx.WithField("filters", filters).Info("final filters has been combine")
-	// 获取总数（含过滤规则）
-	totalCounter, err := coll.CountDocuments(context.TODO(), filters)
-	if err == mongo.ErrNoDocuments {
-		logCtx.Error(err)
-		return nil, 0, err
-	}
-	if err != nil {
-		logCtx.Error(err)
-		return nil, 0, err
-	}
-
-	// 进行必要分页处理
-	opt := options.Find()
-	// 排序方式
-	if urlParams.Sort.SortType == rest.AES {
-		opt.SetSort(bson.M{urlParams.Sort.Key: -1})
-	} else {
-		opt.SetSort(bson.M{urlParams.Sort.Key: 1})
-	}
-
-	opt.SetSkip(int64(urlParams.Range.Offset))
-	opt.SetLimit(int64(urlParams.Range.Limit))
-
-	// 获取数据列表
-	cursor, err := coll.Find(ctx, filters, opt)
-	if err == mongo.ErrNoDocuments {
-		logCtx.Error(err)
-		return nil, totalCounter, err
-	}
-
-	if err != nil {
-		logCtx.Error(err)
-		return nil, totalCounter, err
-	}
-
-	if err = cursor.All(context.TODO(), &results); err != nil {
-		logCtx.Error(err)
-		return nil, totalCounter, err
-	}
-	return results, totalCounter, nil
+// Delete 快速删除
+// 只能删除访问范围内的记录，其他商户的记录返回 errors.ErrNotFound
+func (m *universalModel) Delete(ctx context.Context, scope Scope, id string) error {
+	store := managerAccounts()
+	return store.Delete(ctx, scope, id)
+}
 
+// List 获取列表
+// 与 account.Repo.GetList 相同，需要 context 中有操作者的访问范围，只返回访问范围内的记录
+func (m *universalModel) List(ctx context.Context, scope Scope, urlParams *rest.UrlParams) ([]*universalModel, int64, error) {
+	store := managerAccounts()
+	return store.GetList(ctx, scope, urlParams)
 }
 
 // GetManyInIds 获取条件查询的结果
-func (m *universalModel) GetManyInIds(ctx context.Context, ids []string) ([]*universalModel, error) {
-	// TODO result using custom struct instead of bson.M
-	// because you should avoid to export something to customers
-	coll := db.MDB.Collection(ManagerAccountCollection)
-	// 绑定查询结果
-	results := make([]*universalModel, 0)
-	objIds := make([]*primitive.ObjectID, 0)
-	logCtx := log.WithField("ids", ids)
-
-	for _, i := range ids {
-		objID, _ := primitive.ObjectIDFromHex(i)
-		objIds = append(objIds, &objID)
-	}
-	cursor, err := coll.Find(ctx, bson.M{"_id": bson.M{"$in": objIds}})
-
-	if err != nil {
-		logCtx.Error(err)
-		return nil, err
-	}
-
-	if err = cursor.All(ctx, &results); err != nil {
-		logCtx.Error(err)
-		return nil, err
-	}
-	return results, nil
+// 与 Repository.GetMany 相同，只返回访问范围内的记录
+func (m *universalModel) GetManyInIds(ctx context.Context, scope Scope, ids []string) ([]*universalModel, error) {
+	store := managerAccounts()
+	return store.GetMany(ctx, scope, ids)
 }
 
 // Detail 详情
-func (m *universalModel) Detail(ctx context.Context, id string) (*universalModel, error) {
-	// TODO result using custom struct instead of bson.M
-	// because you should avoid to export something to customers
-	coll := db.MDB.Collection(ManagerAccountCollection)
-	// 绑定查询结果
-	result := &universalModel{}
-	objId, _ := primitive.ObjectIDFromHex(id)
-	filter := bson.D{{Key: "_id", Value: objId}}
+// 只能获取访问范围内的记录
+func (m *universalModel) Detail(ctx context.Context, scope Scope, id string) (*universalModel, error) {
+	store := managerAccounts()
+	return store.GetOne(ctx, scope, id)
+}
 
-	err := coll.FindOne(context.TODO(), filter).Decode(&result)
+// Update 整体更新
+// 只能更新访问范围内的记录，并且记录不能被移动到其他商户下
+func (m *universalModel) Update(ctx context.Context, scope Scope, id string) error {
+	store := managerAccounts()
+	return store.Update(ctx, scope, id, m)
+}
 
-	if err != nil {
-		log.WithField("id", id).Error(err)
-		return result, err
-	}
-	return result, nil
+// MarshalJSON 返回的 JSON 中不包含密码
+func (m universalModel) MarshalJSON() ([]byte, error) {
+	type plain universalModel
+	p := plain(m)
+	p.Password = ""
+	return json.Marshal(p)
 }
 
-// Update 更新
-func (m *universalModel) Update(ctx context.Context, id string) error {
-	// TODO result using custom struct instead of bson.M
-	// because you should avoid to export something to customers
-	coll := db.MDB.Collection(ManagerAccountCollection)
-	objId, _ := primitive.ObjectIDFromHex(id)
-	filter := bson.D{{Key: "_id", Value: objId}}
-	m.UpdatedAt = rtime.FomratTimeAsReader(time.Now().Unix())
+// VerifyPassword 校验密码
+func (m *universalModel) VerifyPassword(plain string) bool {
+	ok, _ := VerifyPassword(m.Password, plain)
+	return ok
+}
 
-	result, err := coll.UpdateOne(ctx, filter,
-		bson.D{{Key: "$set", Value: m}})
+// Authenticate 登录时校验密码
+// m 一般先通过 FindByPhone 获取；保存的是明文或者强度不足时，校验通过后自动重新计算哈希值
+func (m *universalModel) Authenticate(ctx context.Context, plain string) (bool, error) {
+	ok, rehash := VerifyPassword(m.Password, plain)
+	if !ok || !rehash {
+		return ok, nil
+	}
+	store := managerAccounts()
+	hash, err := HashPassword(plain)
 	if err != nil {
-		log.WithField("id", id).Error(err)
-		return err
+		return true, err
 	}
-
-	if result.MatchedCount < 1 {
-		return errors.New("no matched record")
+	err = store.SetPassword(ctx, m.ID, hash)
+	// 从旧表中找到的账号更新旧表
+	if errors.Is(err, cerrors.ErrNotFound) && !isLegacyStore(store) {
+		err = legacyManagerAccounts{}.SetPassword(ctx, m.ID, hash)
 	}
-
-	return nil
+	if err != nil {
+		log.WithField("id", m.ID.Hex()).Error(err)
+		return true, err
+	}
+	m.Password = hash
+	return true, nil
 }
@@ -0,0 +1,27 @@
+package card
+
+import (
+	"errors"
+	"testing"
+
+	cerrors "github.com/r2day/collections/errors"
+)
+
+func TestRender(t *testing.T) {
+	stored, err := Stored{}.Render("12.50", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Total.Units != 1250 || stored.Counter != 3 {
+		t.Fatalf("unexpected stored %+v", stored)
+	}
+	if _, err := (Stored{}).Render("1", "many"); !errors.Is(err, cerrors.ErrValidation) {
+		t.Fatalf("invalid counter should be rejected, got %v", err)
+	}
+	if _, err := (Consumption{}).Render("1", "-1"); !errors.Is(err, cerrors.ErrValidation) {
+		t.Fatalf("negative counter should be rejected, got %v", err)
+	}
+	if consumption, err := (Consumption{}).Render("", ""); err != nil || consumption.Counter != 0 {
+		t.Fatalf("empty values should be zero, got %+v %v", consumption, err)
+	}
+}
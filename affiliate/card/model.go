@@ -1,7 +1,9 @@
 package card
 
 import (
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+
+	"github.com/r2day/collections"
 )
 
 const (
@@ -20,19 +22,17 @@ const (
 // DebitQuota 挂帐额度
 type DebitQuota struct {
 	// 挂帐总额度
-	Total float64 `json:"total" bson:"total"`
+	Total collections.Money `json:"total" bson:"total"`
 	// 挂帐剩余额度
-	Left float64 `json:"left" bson:"left"`
+	Left collections.Money `json:"left" bson:"left"`
 	// 已用额度
-	Used float64 `json:"used" bson:"used"`
+	Used collections.Money `json:"used" bson:"used"`
 }
 
-
-
 // Stored 储值
 type Stored struct {
 	// 总额
-	Total float64 `json:"total" bson:"total"`
+	Total collections.Money `json:"total" bson:"total"`
 	// 总数
 	Counter uint64 `json:"counter" bson:"counter"`
 }
@@ -40,20 +40,20 @@ type Stored struct {
 // Consumption 消费
 type Consumption struct {
 	// 总额
-	Total float64 `json:"total" bson:"total"`
+	Total collections.Money `json:"total" bson:"total"`
 	// 总数
 	Counter uint64 `json:"counter" bson:"counter"`
 }
 
 // Assets 资产信息
 type Assets struct {
-	Balance float64 `json:"balance"  bson:"balance"`
+	Balance collections.Money `json:"balance"  bson:"balance"`
 	// 现金卡值
-	CashCharge float64 `json:"cash_charge" bson:"cash_charge"`
+	CashCharge collections.Money `json:"cash_charge" bson:"cash_charge"`
 	// 冻结卡值
-	Freezing float64 `json:"freezing" bson:"freezing"`
+	Freezing collections.Money `json:"freezing" bson:"freezing"`
 	// 赠送卡值
-	Gift float64 `json:"gift" bson:"gift"`
+	Gift collections.Money `json:"gift" bson:"gift"`
 	// 积分余额
 	Integral uint64 `json:"integral" bson:"integral"`
 
@@ -108,20 +108,7 @@ type BasicInformation struct {
 // Model 模型
 type Model struct {
 	// 基本的数据库模型字段，一般情况所有model都应该包含如下字段
-	// 创建时（用户上传的数据为空，所以默认可以不传该值)
-	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	// 商户号
-	MerchantID string `json:"merchant_id" bson:"merchant_id"`
-	// 创建者
-	AccountID string `json:"account_id" bson:"account_id"`
-	// 创建时间
-	CreatedAt string `json:"created_at" bson:"created_at"`
-	// 更新时间
-	UpdatedAt string `json:"updated_at" bson:"updated_at"`
-	// 状态
-	Status bool `json:"status"`
-	// 根据角色的最低级别写入
-	AccessLevel uint `json:"access_level" bson:"access_level"`
+	collections.BaseModel `bson:",inline"`
 
 	// 用户信息
 	UserInfo UserInformation `json:"user_info" bson:"user_info"`
@@ -139,7 +126,7 @@ type Model struct {
 	Number string `json:"number" bson:"number"`
 	// OpeningDate 开卡日期
 	// 可用的数量
-	OpeningDate string `json:"opening_date" bson:"opening_date"`
+	OpeningDate time.Time `json:"opening_date" bson:"opening_date"`
 	// 手机号验证
 	// 会员迁移后需要进行短信验证完成数据与账号的绑定
 	Verify bool `json:"verify"`
@@ -1,7 +1,9 @@
 package member
 
 import (
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+
+	"github.com/r2day/collections"
 )
 
 const (
@@ -26,20 +28,7 @@ const (
 // Model 模型
 type Model struct {
 	// 基本的数据库模型字段，一般情况所有model都应该包含如下字段
-	// 创建时（用户上传的数据为空，所以默认可以不传该值)
-	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	// 商户号
-	MerchantID string `json:"merchant_id" bson:"merchant_id"`
-	// 创建者
-	AccountID string `json:"account_id" bson:"account_id"`
-	// 创建时间
-	CreatedAt string `json:"created_at" bson:"created_at"`
-	// 更新时间
-	UpdatedAt string `json:"updated_at" bson:"updated_at"`
-	// 状态
-	Status bool `json:"status"`
-	// 根据角色的最低级别写入
-	AccessLevel uint `json:"access_level" bson:"access_level"`
+	collections.BaseModel `bson:",inline"`
 
 	// 客户编号
 	CustomerID string `json:"customer_id" bson:"customer_id"`
@@ -56,7 +45,7 @@ type Model struct {
 	// 来源方式
 	From string `json:"from" bson:"from"`
 	// 注册时间
-	RegisterDate string `json:"register_date" bson:"register_date"`
+	RegisterDate time.Time `json:"register_date" bson:"register_date"`
 	// 优惠券
 	// 可用的数量
 	Coupon int `json:"coupon" bson:"coupon"`
@@ -1,7 +1,7 @@
 package membership
 
 import (
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"github.com/r2day/collections"
 )
 
 const (
@@ -34,15 +34,15 @@ type Settlement struct {
 // FeeSetting 费用设置
 type FeeSetting struct {
 	// 线下开卡工本费
-	OfflineOpenCard float64 `json:"offline_open_card" bson:"offline_open_card"`
+	OfflineOpenCard collections.Money `json:"offline_open_card" bson:"offline_open_card"`
 	// 线上开卡工本费
-	OnlineOpenCard float64 `json:"online_open_card" bson:"online_open_card"`
+	OnlineOpenCard collections.Money `json:"online_open_card" bson:"online_open_card"`
 	// 线下补办收取工本费
 	IsRequireFeeSecond bool `json:"is_require_fee_second" bson:"is_require_fee_second"`
 	// 开卡押金
-	Deposit float64 `json:"deposit" bson:"deposit"`
+	Deposit collections.Money `json:"deposit" bson:"deposit"`
 	// 储值并开卡
-	ChargerAndOpen float64 `json:"charger_and_open" bson:"charger_and_open"`
+	ChargerAndOpen collections.Money `json:"charger_and_open" bson:"charger_and_open"`
 }
 
 // TradeSetting 交易设置
@@ -53,16 +53,16 @@ type TradeSetting struct {
 	IsOnlineCharger bool `json:"is_online_charger" bson:"is_online_charger"`
 	// 交易是否限制
 	TradeLimit string `json:"trade_limit" bson:"trade_limit"`
-	// 储值限额金额 -1 表示不限制
-	StoredMaxLimit float64 `json:"stored_max_limit" bson:"stored_max_limit"`
+	// 储值限额金额 负数表示不限制
+	StoredMaxLimit collections.Money `json:"stored_max_limit" bson:"stored_max_limit"`
 	// 消息推送
 	MsgPush string `json:"msg_push" bson:"msg_push"`
 	// 交易校验
 	Verify bool `json:"verify" bson:"verify"`
 	// 会员卡支付适用业务类型
 	SupportBusinessCategory []string `json:"support_business_category" bson:"support_business_category"`
-	// 卡值消费金额限制 -1 表示不限制
-	ConsumptionMaxValueLimit float64 `json:"consumption_max_value_limit" bson:"consumption_max_value_limit"`
+	// 卡值消费金额限制 负数表示不限制
+	ConsumptionMaxValueLimit collections.Money `json:"consumption_max_value_limit" bson:"consumption_max_value_limit"`
 	// 卡值消费次数限制 -1 表示不限制
 	ConsumptionMaxTimesLimit int `json:"consumption_max_times_limit" bson:"consumption_max_times_limit"`
 	// 是否可注销
@@ -92,20 +92,7 @@ type ParamsSetting struct {
 // Model 模型
 type Model struct {
 	// 基本的数据库模型字段，一般情况所有model都应该包含如下字段
-	// 创建时（用户上传的数据为空，所以默认可以不传该值)
-	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	// 商户号
-	MerchantID string `json:"merchant_id" bson:"merchant_id"`
-	// 创建者
-	AccountID string `json:"account_id" bson:"account_id"`
-	// 创建时间
-	CreatedAt string `json:"created_at" bson:"created_at"`
-	// 更新时间
-	UpdatedAt string `json:"updated_at" bson:"updated_at"`
-	// 状态
-	Status bool `json:"status"`
-	// 根据角色的最低级别写入
-	AccessLevel uint `json:"access_level" bson:"access_level"`
+	collections.BaseModel `bson:",inline"`
 
 	// 姓名
 	Name string `json:"name" bson:"name"`
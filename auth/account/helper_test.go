@@ -0,0 +1,60 @@
+package account_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/r2day/collections"
+)
+
+// merchantA 测试使用的商户以及访问范围
+var merchantA = collections.Scope{MerchantID: "m-a", AccessLevel: collections.LevelAdmin, AccountID: "acc-a"}
+
+// as 返回包含操作者访问范围的 context
+func as(scope collections.Scope) context.Context {
+	return collections.WithScope(context.Background(), scope)
+}
+
+// equal 判断两个列表是否相同
+func equal(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// expectError 检查错误类型
+func expectError(t *testing.T, err error, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Fatalf("expected %v, got %v", target, err)
+	}
+}
+
+// useStore 在测试期间使用指定的默认存储后端
+func useStore(t *testing.T, store collections.Store) {
+	t.Helper()
+	collections.SetDefaultStore(store)
+	t.Cleanup(func() { collections.SetDefaultStore(nil) })
+}
+
+// saveManager 保存旧的管理员账号并返回id
+func saveManager(t *testing.T, merchantID string, phone string, name string) string {
+	t.Helper()
+	ctx := as(collections.Scope{MerchantID: merchantID, AccessLevel: collections.LevelAdmin})
+	m := &collections.ManagerAccountModel{Phone: phone, Name: name}
+	if err := m.SimpleSave(ctx); err != nil {
+		t.Fatal(err)
+	}
+	found := &collections.ManagerAccountModel{Phone: phone}
+	if err := found.FindByPhone(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return found.ID.Hex()
+}
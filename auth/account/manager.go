@@ -0,0 +1,344 @@
+package account
+
+import (
+	"context"
+	"errors"
+
+	"github.com/r2day/collections"
+	cerrors "github.com/r2day/collections/errors"
+	"github.com/r2day/rest"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// managerBatchSize 每批迁移的管理员账号数
+const managerBatchSize = 500
+
+// FromManagerAccount 将旧的管理员账号转换为账号模型
+func FromManagerAccount(old *collections.ManagerAccountModel) *Model {
+	m := &Model{
+		IsAdmin:           old.IsAdmin,
+		Phone:             old.Phone,
+		Password:          old.Password,
+		IsRequiredApprove: old.IsRequiredApprove,
+		Name:              old.Name,
+		Email:             old.Email,
+		Roles:             append([]string{}, old.Roles...),
+	}
+	m.ID = old.ID
+	m.MerchantID = old.MerchantId
+	m.AccountID = old.AccountId
+	// 没有账号id的历史数据使用记录的id
+	if m.AccountID == "" && !old.ID.IsZero() {
+		m.AccountID = old.ID.Hex()
+	}
+	m.CreatedAt = old.CreatedAt
+	m.UpdatedAt = old.UpdatedAt
+	m.Status = old.Status
+	return m
+}
+
+// ManagerAccount 转换为旧的管理员账号
+// 用于兼容仍然使用 collections.ManagerAccountModel 的调用方
+func (m *Model) ManagerAccount() *collections.ManagerAccountModel {
+	return &collections.ManagerAccountModel{
+		ID:                m.ID,
+		CreatedAt:         m.CreatedAt,
+		UpdatedAt:         m.UpdatedAt,
+		IsRequiredApprove: m.IsRequiredApprove,
+		Status:            m.Status,
+		MerchantId:        m.MerchantID,
+		IsAdmin:           m.IsAdmin,
+		Phone:             m.Phone,
+		Password:          m.Password,
+		Name:              m.Name,
+		AccountId:         m.AccountID,
+		Email:             m.Email,
+		Roles:             append([]string{}, m.Roles...),
+	}
+}
+
+// PhoneConflict 手机号冲突
+// 旧表中的账号与新表中同一商户下相同手机号的账号不是同一条记录
+type PhoneConflict struct {
+	// 商户号
+	MerchantID string
+	// 手机号
+	Phone string
+	// 旧表中的记录id
+	SourceID string
+	// 新表中已经存在的记录id
+	TargetID string
+	// 取值不同的字段，保留新表中的值
+	Fields []string
+}
+
+// ManagerMigrationResult 管理员账号迁移结果
+type ManagerMigrationResult struct {
+	// 复制到新表的记录数
+	Copied int64
+	// 合并到新表中已有账号的记录数
+	Merged int64
+	// 已经迁移过的记录数
+	Skipped int64
+	// 手机号冲突
+	Conflicts []PhoneConflict
+	// 无法迁移的记录id
+	Failed []string
+}
+
+// MigrateManagerAccounts 将旧表 sys_manage_account 中的管理员账号迁移到账号表
+// 保留原有的id以及创建时间，明文密码会计算哈希值，可以重复执行
+// 同一商户下手机号已经存在时合并到已有的账号：只补充为空的字段并合并角色，冲突记录在 Conflicts 中
+// 迁移的账号访问级别为 LevelManager，不满足校验规则的记录返回在 Failed 中
+// 旧表中的记录不会被修改或者删除
+func (m *Model) MigrateManagerAccounts(ctx context.Context) (*ManagerMigrationResult, error) {
+	return MigrateManagerAccounts(ctx, Repo.Store().Collection(collections.ManagerAccountCollection), Repo)
+}
+
+// MigrateManagerAccounts 将 source 中的管理员账号迁移到 target 的账号表
+// 例如按商户分库时 target 为 Repo.UsingStore(store)
+func MigrateManagerAccounts(ctx context.Context, source collections.Collection, target *collections.Repository[Model, *Model]) (*ManagerMigrationResult, error) {
+	result := &ManagerMigrationResult{Conflicts: make([]PhoneConflict, 0), Failed: make([]string, 0)}
+	var last primitive.ObjectID
+	for {
+		filter := bson.D{}
+		if !last.IsZero() {
+			filter = bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: last}}}}
+		}
+		opts := &collections.FindOptions{Sort: bson.D{{Key: "_id", Value: 1}}, Limit: managerBatchSize}
+		raws, err := source.Find(ctx, filter, opts)
+		if err != nil {
+			return result, err
+		}
+		for _, raw := range raws {
+			id, _ := raw.Lookup("_id").ObjectIDOK()
+			last = id
+			// 旧表中可能存在字符串格式的时间，只在内存中转换
+			doc, err := collections.ConvertTimeFields(raw, "created_at", "updated_at")
+			old := &collections.ManagerAccountModel{}
+			if err == nil {
+				err = bson.Unmarshal(doc, old)
+			}
+			if err != nil {
+				log.WithField("id", id.Hex()).Error(err)
+				result.Failed = append(result.Failed, id.Hex())
+				continue
+			}
+			if err := migrateManagerAccount(ctx, target, old, result); err != nil {
+				log.WithField("id", old.ID.Hex()).Error(err)
+				result.Failed = append(result.Failed, old.ID.Hex())
+			}
+		}
+		if len(raws) < managerBatchSize {
+			return result, nil
+		}
+	}
+}
+
+// migrateManagerAccount 迁移一个管理员账号
+// 已经迁移过或者合并过的记录计入 Skipped
+func migrateManagerAccount(ctx context.Context, target *collections.Repository[Model, *Model], old *collections.ManagerAccountModel, result *ManagerMigrationResult) error {
+	coll := target.Store().Collection(target.CollectionName())
+	if _, err := coll.FindOne(ctx, bson.D{{Key: "_id", Value: old.ID}}); err == nil {
+		result.Skipped++
+		return nil
+	} else if err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	m := FromManagerAccount(old)
+	if m.Password != "" {
+		hash, err := collections.LegacyPasswordHash(m.Password)
+		if err != nil {
+			return err
+		}
+		m.Password = hash
+	}
+	existing, err := target.FindOne(ctx, bson.D{
+		{Key: "merchant_id", Value: m.MerchantID},
+		{Key: "phone", Value: m.Phone},
+	})
+	if errors.Is(err, cerrors.ErrNotFound) {
+		// 旧表中没有版本号以及访问级别，管理员账号只有主管及以上的级别可以访问
+		m.Version = 1
+		m.AccessLevel = collections.LevelManager
+		// 保留原有的创建时间以及密码哈希值，不使用 Create，但是同样需要满足校验规则
+		if err := target.Validate(m); err != nil {
+			return err
+		}
+		if _, err := coll.InsertOne(ctx, m); err != nil {
+			return err
+		}
+		result.Copied++
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	conflict := PhoneConflict{
+		MerchantID: m.MerchantID,
+		Phone:      m.Phone,
+		SourceID:   old.ID.Hex(),
+		TargetID:   existing.ID.Hex(),
+		Fields:     make([]string, 0),
+	}
+	set := make(map[string]interface{})
+	fill := func(field string, target string, source string) {
+		switch {
+		case source == "" || target == source:
+		case target == "":
+			set[field] = source
+		default:
+			conflict.Fields = append(conflict.Fields, field)
+		}
+	}
+	fill("name", existing.Name, m.Name)
+	fill("email", existing.Email, m.Email)
+	if existing.Password == "" && m.Password != "" {
+		set[passwordField] = collections.PasswordHash(m.Password)
+	} else if m.Password != "" && !samePassword(existing.Password, old.Password) {
+		conflict.Fields = append(conflict.Fields, passwordField)
+	}
+	if existing.IsAdmin != m.IsAdmin {
+		conflict.Fields = append(conflict.Fields, "is_admin")
+	}
+	roles := append([]string{}, existing.Roles...)
+	for _, role := range m.Roles {
+		if !contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) > len(existing.Roles) {
+		set["roles"] = roles
+	}
+	if len(conflict.Fields) > 0 {
+		result.Conflicts = append(result.Conflicts, conflict)
+	}
+
+	if len(set) == 0 {
+		result.Skipped++
+		return nil
+	}
+	scope := collections.Scope{MerchantID: existing.MerchantID, AccessLevel: existing.AccessLevel}
+	if err := target.Patch(collections.WithScope(ctx, scope), scope, existing.ID.Hex(), collections.Patch{Set: set}); err != nil {
+		return err
+	}
+	result.Merged++
+	return nil
+}
+
+// managerAccountStore 将 collections.ManagerAccountModel 的读写委托给账号表
+type managerAccountStore struct {
+	repo *collections.Repository[Model, *Model]
+}
+
+// ManagerAccountStore 返回读写账号表的管理员账号存储
+// 迁移之后通过 collections.SetManagerAccountStore(account.ManagerAccountStore(account.Repo)) 使用
+func ManagerAccountStore(repo *collections.Repository[Model, *Model]) collections.ManagerAccountStore {
+	return managerAccountStore{repo: repo}
+}
+
+// Create 创建账号
+func (s managerAccountStore) Create(ctx context.Context, old *collections.ManagerAccountModel) error {
+	m := FromManagerAccount(old)
+	if _, err := s.repo.Create(ctx, m); err != nil {
+		return err
+	}
+	*old = *m.ManagerAccount()
+	return nil
+}
+
+// UpdateByID 通过id整体更新账号
+func (s managerAccountStore) UpdateByID(ctx context.Context, old *collections.ManagerAccountModel) error {
+	return s.repo.UpdateByID(ctx, old.ID, FromManagerAccount(old))
+}
+
+// Update 整体更新访问范围内的账号
+func (s managerAccountStore) Update(ctx context.Context, scope collections.Scope, id string, old *collections.ManagerAccountModel) error {
+	return s.repo.Update(ctx, scope, id, FromManagerAccount(old))
+}
+
+// Delete 删除访问范围内的账号
+func (s managerAccountStore) Delete(ctx context.Context, scope collections.Scope, id string) error {
+	return s.repo.Delete(ctx, scope, id)
+}
+
+// FindOne 通过过滤条件查找一个账号
+func (s managerAccountStore) FindOne(ctx context.Context, filter bson.D) (*collections.ManagerAccountModel, error) {
+	m, err := s.repo.FindOne(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return m.ManagerAccount(), nil
+}
+
+// GetOne 获取访问范围内的账号
+func (s managerAccountStore) GetOne(ctx context.Context, scope collections.Scope, id string) (*collections.ManagerAccountModel, error) {
+	m, err := s.repo.GetOne(ctx, scope, id)
+	if err != nil {
+		return nil, err
+	}
+	return m.ManagerAccount(), nil
+}
+
+// GetMany 获取访问范围内指定id的账号
+func (s managerAccountStore) GetMany(ctx context.Context, scope collections.Scope, ids []string) ([]*collections.ManagerAccountModel, error) {
+	list, err := s.repo.GetMany(ctx, scope, ids)
+	if err != nil {
+		return nil, err
+	}
+	return managerAccountsOf(list), nil
+}
+
+// GetList 获取访问范围内的账号列表
+func (s managerAccountStore) GetList(ctx context.Context, scope collections.Scope, urlParams *rest.UrlParams) ([]*collections.ManagerAccountModel, int64, error) {
+	list, total, err := s.repo.GetList(ctx, scope, urlParams)
+	if err != nil {
+		return nil, total, err
+	}
+	return managerAccountsOf(list), total, nil
+}
+
+// SetPassword 保存已经计算好的密码哈希值
+func (s managerAccountStore) SetPassword(ctx context.Context, id primitive.ObjectID, hash string) error {
+	m, err := s.repo.FindOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return err
+	}
+	scope := collections.Scope{MerchantID: m.MerchantID, AccessLevel: m.AccessLevel, AccountID: m.AccountID}
+	patch := collections.Patch{Set: bson.M{passwordField: collections.PasswordHash(hash)}}
+	return s.repo.Patch(collections.WithScope(ctx, scope), scope, id.Hex(), patch)
+}
+
+// managerAccountsOf 将账号列表转换为旧的管理员账号
+func managerAccountsOf(list []*Model) []*collections.ManagerAccountModel {
+	results := make([]*collections.ManagerAccountModel, 0, len(list))
+	for _, m := range list {
+		results = append(results, m.ManagerAccount())
+	}
+	return results
+}
+
+// samePassword 判断旧表中的密码与已有账号的密码是否相同
+// 旧表中保存的可能是明文，哈希值只能逐字比较
+func samePassword(hash string, old string) bool {
+	if collections.IsPasswordHash(old) {
+		return hash == old
+	}
+	ok, _ := collections.VerifyPassword(hash, old)
+	return ok
+}
+
+// contains 判断列表中是否包含指定的值
+func contains(list []string, val string) bool {
+	for _, v := range list {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
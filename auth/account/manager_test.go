@@ -0,0 +1,147 @@
+package account_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/r2day/collections"
+	"github.com/r2day/collections/auth/account"
+	cerrors "github.com/r2day/collections/errors"
+	"github.com/r2day/collections/memory"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestManagerAccountUsesAccounts(t *testing.T) {
+	store := memory.NewStore()
+	useStore(t, store)
+	ctx := context.Background()
+	err := (&collections.ManagerAccountModel{Phone: "13800138000"}).SimpleSave(ctx)
+	expectError(t, err, cerrors.ErrForbiddenLevel)
+
+	// 默认读写旧表
+	legacyID := saveManager(t, merchantA.MerchantID, "13800138009", "legacy")
+	if _, err := store.Collection(collections.ManagerAccountCollection).FindOne(ctx, bson.D{{Key: "phone", Value: "13800138009"}}); err != nil {
+		t.Fatalf("the legacy table should be used by default, got %v", err)
+	}
+
+	collections.SetManagerAccountStore(account.ManagerAccountStore(account.Repo))
+	t.Cleanup(func() { collections.SetManagerAccountStore(nil) })
+	id := saveManager(t, merchantA.MerchantID, "13800138000", "a")
+	acc, err := account.Repo.GetOne(as(merchantA), merchantA, id)
+	if err != nil || acc.Name != "a" || acc.AccountID != id {
+		t.Fatalf("legacy writes should go to the account table, got %+v %v", acc, err)
+	}
+	if err := account.Repo.Patch(as(merchantA), merchantA, id, collections.Patch{Set: bson.M{"name": "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	found := &collections.ManagerAccountModel{AccountId: id}
+	if err := found.FindByAccountId(ctx); err != nil || found.Name != "b" {
+		t.Fatalf("legacy reads should see account writes, got %+v %v", found, err)
+	}
+	if _, err := account.Repo.GetOne(as(merchantA), merchantA, legacyID); !errors.Is(err, cerrors.ErrNotFound) {
+		t.Fatalf("legacy accounts should not be copied without migration, got %v", err)
+	}
+}
+
+func TestMigrateManagerAccounts(t *testing.T) {
+	useStore(t, memory.NewStore())
+	ctx := context.Background()
+	source := memory.NewStore().Collection(collections.ManagerAccountCollection)
+	target := account.Repo.UsingStore(memory.NewStore())
+	legacy := func(phone string, name string, email string) primitive.ObjectID {
+		t.Helper()
+		id := primitive.NewObjectID()
+		doc := bson.D{
+			{Key: "_id", Value: id},
+			{Key: "merchant_id", Value: merchantA.MerchantID},
+			{Key: "phone", Value: phone},
+			{Key: "name", Value: name},
+			{Key: "email", Value: email},
+			{Key: "created_at", Value: "2023-01-02 03:04:05"},
+		}
+		if _, err := source.InsertOne(ctx, doc); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	existing := func(phone string, name string, email string) {
+		t.Helper()
+		m := &account.Model{Phone: phone, Name: name, Email: email}
+		m.AccountID = "acc-" + phone
+		if _, err := target.Create(as(merchantA), m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	copied := legacy("13800138001", "a", "")
+	legacy("13800138002", "b", "b@example.com")
+	existing("13800138002", "", "x@example.com")
+	legacy("13800138003", "c", "")
+	existing("13800138003", "c", "")
+	invalid := legacy("not-a-phone", "d", "")
+
+	result, err := account.MigrateManagerAccounts(ctx, source, target)
+	if err != nil || result.Copied != 1 || result.Merged != 1 || result.Skipped != 1 || !equal(result.Failed, []string{invalid.Hex()}) {
+		t.Fatalf("unexpected result %+v %v", result, err)
+	}
+	if len(result.Conflicts) != 1 || !equal(result.Conflicts[0].Fields, []string{"email"}) {
+		t.Fatalf("only accounts with different fields should conflict, got %+v", result.Conflicts)
+	}
+	m, err := target.GetOne(as(merchantA), merchantA, copied.Hex())
+	if err != nil || m.CreatedAt.Year() != 2023 {
+		t.Fatalf("times should be converted, got %+v %v", m, err)
+	}
+	if m.AccessLevel != collections.LevelManager {
+		t.Fatalf("migrated accounts should be at manager level, got %s", m.AccessLevel)
+	}
+	raw, err := source.FindOne(ctx, bson.D{{Key: "_id", Value: copied}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := raw.Lookup("created_at").StringValueOK(); !ok {
+		t.Fatal("the source should not be modified")
+	}
+	if _, err := account.Repo.GetOne(as(merchantA), merchantA, copied.Hex()); !errors.Is(err, cerrors.ErrNotFound) {
+		t.Fatal("accounts should only be written to the target")
+	}
+
+	result, err = account.MigrateManagerAccounts(ctx, source, target)
+	if err != nil || result.Copied != 0 || result.Merged != 0 || result.Skipped != 3 {
+		t.Fatalf("migrated accounts should be skipped, got %+v %v", result, err)
+	}
+}
+
+func TestManagerAccountLegacyLogin(t *testing.T) {
+	store := memory.NewStore()
+	useStore(t, store)
+	collections.SetManagerAccountStore(account.ManagerAccountStore(account.Repo))
+	t.Cleanup(func() { collections.SetManagerAccountStore(nil) })
+	ctx := context.Background()
+	legacy := store.Collection(collections.ManagerAccountCollection)
+	doc := bson.D{
+		{Key: "_id", Value: primitive.NewObjectID()},
+		{Key: "merchant_id", Value: merchantA.MerchantID},
+		{Key: "phone", Value: "13800138000"},
+		{Key: "password", Value: "secret"},
+	}
+	if _, err := legacy.InsertOne(ctx, doc); err != nil {
+		t.Fatal(err)
+	}
+
+	// 还没有迁移的账号可以继续登录
+	m := &collections.ManagerAccountModel{Phone: "13800138000"}
+	if err := m.FindByPhone(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := m.Authenticate(ctx, "secret"); !ok || err != nil {
+		t.Fatalf("legacy accounts should still log in, got %v %v", ok, err)
+	}
+	raw, err := legacy.FindOne(ctx, bson.D{{Key: "phone", Value: "13800138000"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password := raw.Lookup("password").StringValue(); !collections.IsPasswordHash(password) {
+		t.Fatalf("the legacy password should be rehashed, got %s", password)
+	}
+}
@@ -1,7 +1,7 @@
 package account
 
 import (
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"github.com/r2day/collections"
 )
 
 const (
@@ -25,23 +25,7 @@ const (
 // Model 模型
 type Model struct {
 	// 基本的数据库模型字段，一般情况所有model都应该包含如下字段
-	// 创建时（用户上传的数据为空，所以默认可以不传该值)
-	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	// 商户id
-	// 如果用户部署为单机模式，则商户号为固定值
-	// 一般用于部署在私有化的时候启动
-	// 在其他模式下，MerchantID 起到命名空间的作用
-	MerchantID string `json:"merchant_id" bson:"merchant_id"`
-	// 创建者
-	AccountID string `json:"account_id" bson:"account_id"`
-	// 创建时间
-	CreatedAt string `json:"created_at" bson:"created_at"`
-	// 更新时间
-	UpdatedAt string `json:"updated_at" bson:"updated_at"`
-	// 状态
-	Status bool `json:"status"`
-	// 根据角色的最低级别写入
-	AccessLevel uint `json:"access_level" bson:"access_level"`
+	collections.BaseModel `bson:",inline"`
 
 	// IsAdmin 是否是管理员
 	// 一般standalone 模式下，是通过读取部署时配置的 ADMIN_PHONE
@@ -52,7 +36,8 @@ type Model struct {
 	// 手机号
 	Phone string `json:"phone"`
 	// 密码
-	Password string `json:"password"  bson:"password"`
+	// 保存的是 bcrypt 哈希值，返回的 JSON 中不包含该字段
+	Password string `json:"password,omitempty"  bson:"password"`
 
 	// 是否开启审核
 	IsRequiredApprove bool `json:"is_required_approve" bson:"is_required_approve"`
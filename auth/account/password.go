@@ -0,0 +1,54 @@
+package account
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/r2day/collections"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// passwordField 密码字段
+const passwordField = "password"
+
+// MarshalJSON 返回的 JSON 中不包含密码
+func (m Model) MarshalJSON() ([]byte, error) {
+	type plain Model
+	p := plain(m)
+	p.Password = ""
+	return json.Marshal(p)
+}
+
+// VerifyPassword 校验密码
+func (m *Model) VerifyPassword(plain string) bool {
+	ok, _ := collections.VerifyPassword(m.Password, plain)
+	return ok
+}
+
+// Authenticate 登录时校验密码
+// m 一般先通过 FindByPhone 获取；保存的是明文或者强度不足时，校验通过后自动重新计算哈希值
+func (m *Model) Authenticate(ctx context.Context, plain string) (bool, error) {
+	ok, rehash := collections.VerifyPassword(m.Password, plain)
+	if !ok || !rehash {
+		return ok, nil
+	}
+	// 登录时还没有操作者，以账号自身的访问范围更新
+	scope := collections.Scope{MerchantID: m.MerchantID, AccessLevel: m.AccessLevel, AccountID: m.AccountID}
+	if err := m.ChangePassword(collections.WithScope(ctx, scope), scope, m.ID.Hex(), plain); err != nil {
+		log.WithField("id", m.ID.Hex()).Error(err)
+		return true, err
+	}
+	return true, nil
+}
+
+// ChangePassword 修改密码
+// 保存时计算哈希值，成功后 m 中的密码被清空，之后整体更新时保持不变
+func (m *Model) ChangePassword(ctx context.Context, scope collections.Scope, id string, plain string) error {
+	patch := collections.Patch{Set: bson.M{passwordField: plain}}
+	if err := Repo.Patch(ctx, scope, id, patch); err != nil {
+		return err
+	}
+	m.Password = ""
+	return nil
+}
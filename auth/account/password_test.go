@@ -0,0 +1,76 @@
+package account_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/r2day/collections/auth/account"
+	cerrors "github.com/r2day/collections/errors"
+	"github.com/r2day/collections/memory"
+)
+
+func TestAccountPassword(t *testing.T) {
+	useStore(t, memory.NewStore())
+	ctx := as(merchantA)
+
+	acc := &account.Model{Phone: "13800138000", Password: "secret", Name: "a"}
+	acc.AccountID = "acc-1"
+	id, err := account.Repo.Create(ctx, acc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := &account.Model{Phone: "13800138000"}
+	if err := found.FindByPhone(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := found.Authenticate(ctx, "secret"); !ok || err != nil {
+		t.Fatalf("password should match, got %v %v", ok, err)
+	}
+	if err := found.ChangePassword(as(merchantA), merchantA, id, "changed"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 先查询再整体更新不会修改密码
+	found = &account.Model{Phone: "13800138000"}
+	if err := found.FindByPhone(ctx); err != nil {
+		t.Fatal(err)
+	}
+	found.Name = "b"
+	if err := found.UpdateById(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := found.FindByPhone(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := found.Authenticate(ctx, "changed"); !ok || found.Name != "b" {
+		t.Fatal("password should survive a full update")
+	}
+	if data, _ := json.Marshal(found); strings.Contains(string(data), "password") {
+		t.Fatalf("password should not be returned, got %s", data)
+	}
+}
+
+func TestAccountID(t *testing.T) {
+	repo := account.Repo.UsingStore(memory.NewStore())
+	ctx := as(merchantA)
+	if _, err := repo.EnsureIndexes(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	acc := &account.Model{Phone: "13800138000"}
+	_, err := repo.Create(ctx, acc)
+	expectError(t, err, cerrors.ErrValidation)
+
+	acc.AccountID = "acc-1"
+	if _, err := repo.Create(ctx, acc); err != nil {
+		t.Fatal(err)
+	}
+	if acc.AccountID != "acc-1" || acc.CreatedBy != merchantA.AccountID {
+		t.Fatalf("account_id should not be replaced by the operator, got %s %s", acc.AccountID, acc.CreatedBy)
+	}
+	other := &account.Model{Phone: "13800138001"}
+	other.AccountID = "acc-1"
+	_, err = repo.Create(ctx, other)
+	expectError(t, err, cerrors.ErrConflict)
+}
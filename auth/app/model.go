@@ -2,7 +2,6 @@ package app
 
 import (
 	"github.com/r2day/collections"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 const (
@@ -26,24 +25,15 @@ const (
 // Model 模型
 type Model struct {
 	// 基本的数据库模型字段，一般情况所有model都应该包含如下字段
-	// 创建时（用户上传的数据为空，所以默认可以不传该值)
-	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	// 商户号
-	MerchantID string `json:"merchant_id" bson:"merchant_id"`
-	// 创建者
-	AccountID string `json:"account_id" bson:"account_id"`
-	// 创建时间
-	CreatedAt string `json:"created_at" bson:"created_at"`
-	// 更新时间
-	UpdatedAt string `json:"updated_at" bson:"updated_at"`
-	// 状态
-	Status bool `json:"status"`
+	collections.BaseModel `bson:",inline"`
 	// 名称
 	Name string `json:"name" bson:"name"`
 	// 应用描述
 	Desc string `json:"desc" bson:"desc"`
-	// 根据角色的最低级别写入
-	AccessLevel uint `json:"access_level" bson:"access_level"`
+	// 菜单图标
+	Icon string `json:"icon" bson:"icon"`
+	// 菜单中的顺序，从小到大排列，相同时按照名称
+	Order int `json:"order" bson:"order"`
 
 	// AccessApi 可访问的api列表
 	AccessAPI []collections.APIInfo `json:"access_api"  bson:"access_api"`
@@ -0,0 +1,160 @@
+package permission
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/r2day/collections"
+	"github.com/r2day/collections/auth/account"
+	"github.com/r2day/collections/auth/app"
+	"github.com/r2day/collections/auth/role"
+)
+
+// CacheTTL 权限缓存的有效期
+// 当前进程内修改账号、角色以及应用时会立即清除缓存，其他进程的修改最多在有效期后生效
+var CacheTTL = 5 * time.Minute
+
+// entry 缓存的权限
+type entry struct {
+	policy  *Policy
+	expires time.Time
+}
+
+// Authorizer 存储后端中账号的权限
+// 权限按存储后端分别编译以及缓存，例如按商户分库时每个数据库使用各自的 Authorizer
+type Authorizer struct {
+	store collections.Store
+	// mu 保护 cache 以及 generation
+	mu sync.Mutex
+	// cache 各账号编译后的权限
+	cache map[string]entry
+	// generation 每次清除缓存时加 1
+	// 编译期间缓存被清除时不保存编译结果，避免缓存旧的权限
+	generation uint64
+}
+
+// authorizers 各存储后端的 Authorizer
+var authorizers sync.Map
+
+// For 返回存储后端的 Authorizer
+// 同一个存储后端返回相同的实例
+func For(store collections.Store) *Authorizer {
+	a, _ := authorizers.LoadOrStore(store, &Authorizer{store: store, cache: make(map[string]entry)})
+	return a.(*Authorizer)
+}
+
+// defaultAuthorizer 返回默认存储后端的 Authorizer
+func defaultAuthorizer() *Authorizer {
+	return For(collections.DefaultStore())
+}
+
+func init() {
+	for _, name := range []string{
+		account.Repo.CollectionName(),
+		role.Repo.CollectionName(),
+		app.Repo.CollectionName(),
+	} {
+		collections.OnChange(name, func(ctx context.Context, change collections.Change) error {
+			// 只清除写入的存储后端的缓存
+			if a, ok := authorizers.Load(change.Store); ok {
+				a.(*Authorizer).InvalidateAll()
+			}
+			return nil
+		})
+	}
+}
+
+// Authorize 判断账号是否可以对路径执行操作
+// 使用默认的存储后端，账号不存在时返回 errors.ErrNotFound
+func Authorize(ctx context.Context, accountID string, path string, action Action) (bool, error) {
+	return defaultAuthorizer().Authorize(ctx, accountID, path, action)
+}
+
+// Authorize 判断账号是否可以对路径执行操作
+// 账号不存在时返回 errors.ErrNotFound
+func (a *Authorizer) Authorize(ctx context.Context, accountID string, path string, action Action) (bool, error) {
+	policy, err := a.PolicyOf(ctx, accountID)
+	if err != nil {
+		return false, err
+	}
+	return policy.Allow(path, action), nil
+}
+
+// WithAccount 返回包含账号访问范围的 context
+// 使用默认的存储后端
+func WithAccount(ctx context.Context, accountID string) (context.Context, error) {
+	return defaultAuthorizer().WithAccount(ctx, accountID)
+}
+
+// WithAccount 返回包含账号访问范围的 context
+// 一般在认证之后调用，之后创建的记录的访问级别为账号的级别，更新时不能超过账号的级别
+func (a *Authorizer) WithAccount(ctx context.Context, accountID string) (context.Context, error) {
+	policy, err := a.PolicyOf(ctx, accountID)
+	if err != nil {
+		return ctx, err
+	}
+	return collections.WithScope(ctx, policy.Scope()), nil
+}
+
+// PolicyOf 返回账号的权限
+// 使用默认的存储后端
+func PolicyOf(ctx context.Context, accountID string) (*Policy, error) {
+	return defaultAuthorizer().PolicyOf(ctx, accountID)
+}
+
+// PolicyOf 返回账号的权限
+// 优先使用缓存
+func (a *Authorizer) PolicyOf(ctx context.Context, accountID string) (*Policy, error) {
+	a.mu.Lock()
+	e, ok := a.cache[accountID]
+	gen := a.generation
+	a.mu.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.policy, nil
+	}
+
+	policy, err := a.Compile(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if gen == a.generation {
+		a.cache[accountID] = entry{policy: policy, expires: time.Now().Add(CacheTTL)}
+	}
+	return policy, nil
+}
+
+// Invalidate 清除账号在所有存储后端中的权限缓存
+func Invalidate(accountID string) {
+	authorizers.Range(func(_, a interface{}) bool {
+		a.(*Authorizer).Invalidate(accountID)
+		return true
+	})
+}
+
+// Invalidate 清除账号的权限缓存
+func (a *Authorizer) Invalidate(accountID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.cache, accountID)
+	a.generation++
+}
+
+// InvalidateAll 清除所有存储后端中的权限缓存
+func InvalidateAll() {
+	authorizers.Range(func(_, a interface{}) bool {
+		a.(*Authorizer).InvalidateAll()
+		return true
+	})
+}
+
+// InvalidateAll 清除所有账号的权限缓存
+// 账号、角色以及应用通过数据仓库修改后自动调用
+func (a *Authorizer) InvalidateAll() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.cache = make(map[string]entry)
+	a.generation++
+}
@@ -0,0 +1,41 @@
+package permission_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/r2day/collections"
+)
+
+// 测试使用的商户以及访问范围
+var (
+	merchantA = collections.Scope{MerchantID: "m-a", AccessLevel: collections.LevelAdmin, AccountID: "acc-a"}
+	merchantB = collections.Scope{MerchantID: "m-b", AccessLevel: collections.LevelAdmin, AccountID: "acc-b"}
+)
+
+// as 返回包含操作者访问范围的 context
+func as(scope collections.Scope) context.Context {
+	return collections.WithScope(context.Background(), scope)
+}
+
+// equal 判断两个列表是否相同
+func equal(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// expectError 检查错误类型
+func expectError(t *testing.T, err error, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Fatalf("expected %v, got %v", target, err)
+	}
+}
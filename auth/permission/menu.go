@@ -0,0 +1,68 @@
+package permission
+
+import (
+	"context"
+	"sort"
+)
+
+// MenuItem 菜单项
+// 第一级为应用，第二级为应用中可见的接口
+type MenuItem struct {
+	// 应用id，接口为空
+	ID string `json:"id,omitempty"`
+	// 名称
+	Name string `json:"name"`
+	// 接口路径，应用为空
+	Path string `json:"path,omitempty"`
+	// 图标
+	Icon string `json:"icon"`
+	// 顺序
+	Order int `json:"order"`
+	// 子菜单
+	Children []*MenuItem `json:"children,omitempty"`
+}
+
+// Menu 返回账号的菜单
+// 使用默认的存储后端
+func Menu(ctx context.Context, accountID string) ([]*MenuItem, error) {
+	return defaultAuthorizer().Menu(ctx, accountID)
+}
+
+// Menu 返回账号的菜单
+// 只包含有读权限、未禁用并且没有在 sidebar 中隐藏的接口，没有可见接口的应用不会出现
+// 应用以及接口按照 Order 从小到大排列
+func (a *Authorizer) Menu(ctx context.Context, accountID string) ([]*MenuItem, error) {
+	policy, err := a.PolicyOf(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return policy.Menu(), nil
+}
+
+// Menu 返回权限对应的菜单
+func (p *Policy) Menu() []*MenuItem {
+	menu := make([]*MenuItem, 0, len(p.apps))
+	for _, a := range p.apps {
+		item := &MenuItem{ID: a.ID.Hex(), Name: a.Name, Icon: a.Icon, Order: a.Order, Children: make([]*MenuItem, 0)}
+		for _, api := range a.AccessAPI {
+			if api.HideOnSidebar || api.Disable || !p.Allow(api.Path, Read) {
+				continue
+			}
+			item.Children = append(item.Children, &MenuItem{Name: api.Name, Path: api.Path, Icon: api.Icon, Order: api.Order})
+		}
+		if len(item.Children) == 0 {
+			continue
+		}
+		sort.SliceStable(item.Children, func(i, j int) bool {
+			return item.Children[i].Order < item.Children[j].Order
+		})
+		menu = append(menu, item)
+	}
+	sort.SliceStable(menu, func(i, j int) bool {
+		if menu[i].Order != menu[j].Order {
+			return menu[i].Order < menu[j].Order
+		}
+		return menu[i].Name < menu[j].Name
+	})
+	return menu
+}
@@ -0,0 +1,41 @@
+package permission
+
+import (
+	"path"
+	"strings"
+)
+
+// cleanPath 规范化请求路径
+// 去掉查询参数以及结尾的 /，并且以 / 开头
+func cleanPath(p string) string {
+	if i := strings.IndexAny(p, "?#"); i >= 0 {
+		p = p[:i]
+	}
+	p = strings.Trim(p, "/")
+	return "/" + p
+}
+
+// matchPath 判断请求路径是否匹配规则
+// 规则按照 / 分段匹配：
+// * 匹配任意一段，段内也可以使用 path.Match 的通配符，例如 order*
+// :id 以及 {id} 形式的参数匹配任意一段
+// 结尾的 ** 匹配剩余的零段或者多段，例如 /orders/** 匹配 /orders 以及 /orders/123/items
+func matchPath(pattern string, p string) bool {
+	patterns := strings.Split(strings.TrimPrefix(cleanPath(pattern), "/"), "/")
+	parts := strings.Split(strings.TrimPrefix(cleanPath(p), "/"), "/")
+	for i, seg := range patterns {
+		if seg == "**" && i == len(patterns)-1 {
+			return true
+		}
+		if i >= len(parts) {
+			return false
+		}
+		if strings.HasPrefix(seg, ":") || (strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}")) {
+			continue
+		}
+		if ok, err := path.Match(seg, parts[i]); err != nil || !ok {
+			return false
+		}
+	}
+	return len(patterns) == len(parts)
+}
@@ -0,0 +1,33 @@
+package permission
+
+import "testing"
+
+func TestMatchPath(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"/orders", "/orders", true},
+		{"/orders", "orders/", true},
+		{"/orders", "/orders?page=1", true},
+		{"/orders", "/orders/1", false},
+		{"/orders/*", "/orders/1", true},
+		{"/orders/*", "/orders", false},
+		{"/orders/:id", "/orders/1", true},
+		{"/orders/{id}/items", "/orders/1/items", true},
+		{"/orders/{id}/items", "/orders/1/refunds", false},
+		{"/order*", "/orders", true},
+		{"/order*", "/members", false},
+		{"/orders/**", "/orders", true},
+		{"/orders/**", "/orders/1/items", true},
+		{"/orders/**", "/members/1", false},
+		{"/orders/**/items", "/orders/1/2/items", false},
+		{"/orders/[", "/orders/[", false},
+	}
+	for _, c := range cases {
+		if got := matchPath(c.pattern, c.path); got != c.match {
+			t.Fatalf("%s %s: expected %v", c.pattern, c.path, c.match)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package permission
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/r2day/collections"
+	"github.com/r2day/collections/auth/account"
+	"github.com/r2day/collections/auth/app"
+	"github.com/r2day/collections/auth/role"
+	cerrors "github.com/r2day/collections/errors"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Action 对接口的操作
+// 与 role.PermissionsModel 中的字段一一对应
+type Action string
+
+const (
+	// Read 读，一般对应列表
+	Read Action = "read"
+	// Write 写，一般对应创建
+	Write Action = "write"
+	// Update 改
+	Update Action = "update"
+	// Detail 详情
+	Detail Action = "detail"
+	// Delete 删除
+	Delete Action = "delete"
+)
+
+// MethodAction 返回请求方法对应的操作
+// hasID 表示请求的是单条记录，例如 GET /orders/123 为详情
+func MethodAction(method string, hasID bool) Action {
+	switch method {
+	case http.MethodPost:
+		return Write
+	case http.MethodPut, http.MethodPatch:
+		return Update
+	case http.MethodDelete:
+		return Delete
+	}
+	if hasID {
+		return Detail
+	}
+	return Read
+}
+
+// actionSet 操作的集合
+type actionSet uint8
+
+const (
+	readBit actionSet = 1 << iota
+	writeBit
+	updateBit
+	detailBit
+	deleteBit
+)
+
+// bit 返回操作对应的位，未知的操作返回 0
+func (a Action) bit() actionSet {
+	switch a {
+	case Read:
+		return readBit
+	case Write:
+		return writeBit
+	case Update:
+		return updateBit
+	case Detail:
+		return detailBit
+	case Delete:
+		return deleteBit
+	}
+	return 0
+}
+
+// actionsOf 返回角色权限中允许的操作
+func actionsOf(p role.PermissionsModel) actionSet {
+	var set actionSet
+	if p.Read {
+		set |= readBit
+	}
+	if p.Write {
+		set |= writeBit
+	}
+	if p.Update {
+		set |= updateBit
+	}
+	if p.Detail {
+		set |= detailBit
+	}
+	if p.Delete {
+		set |= deleteBit
+	}
+	return set
+}
+
+// grant 路径上允许的操作
+type grant struct {
+	path    string
+	actions actionSet
+}
+
+// Policy 账号编译后的权限
+// 由账号所有角色的权限合并而成
+type Policy struct {
+	// 账号id
+	AccountID string
+	// 商户号
+	MerchantID string
+	// 访问级别，为所有角色中最高的级别，管理员为 LevelAdmin
+	AccessLevel collections.AccessLevel
+	// 是否是管理员
+	admin bool
+	// 角色允许的操作，相同路径的操作已经合并
+	grants []grant
+	// 角色所选的应用，管理员为所有应用
+	apps []*app.Model
+	// 应用的接口
+	apis []collections.APIInfo
+}
+
+// Allow 判断是否可以对路径执行操作
+// 匹配的应用接口被禁用时任何人都不能访问；匹配的接口都不允许访问详情时不能执行 Detail
+// 管理员不受角色权限的限制，其他账号需要任意一个角色的权限允许该操作
+func (p *Policy) Allow(path string, action Action) bool {
+	bit := action.bit()
+	if bit == 0 {
+		return false
+	}
+	matched, canViewDetail := false, false
+	for _, api := range p.apis {
+		if !matchPath(api.Path, path) {
+			continue
+		}
+		if api.Disable {
+			return false
+		}
+		matched = true
+		canViewDetail = canViewDetail || api.CanViewDetail
+	}
+	if action == Detail && matched && !canViewDetail {
+		return false
+	}
+	if p.admin {
+		return true
+	}
+	for _, g := range p.grants {
+		if g.actions&bit != 0 && matchPath(g.path, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Compile 读取默认存储后端中账号的角色以及应用并编译权限
+// 不使用缓存，一般使用 PolicyOf
+func Compile(ctx context.Context, accountID string) (*Policy, error) {
+	return defaultAuthorizer().Compile(ctx, accountID)
+}
+
+// Compile 读取账号的角色以及应用并编译权限
+// 不使用缓存，一般使用 PolicyOf；账号停用（Status 为 false）或者已删除时返回 errors.ErrDisabled
+func (a *Authorizer) Compile(ctx context.Context, accountID string) (*Policy, error) {
+	logCtx := log.WithField("accountID", accountID)
+	acc, err := account.Repo.UsingStore(a.store).FindOne(ctx, bson.D{{Key: "account_id", Value: accountID}})
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+	if !acc.Status || acc.DeletedAt != nil {
+		err := fmt.Errorf("%w: account %s", cerrors.ErrDisabled, accountID)
+		logCtx.Error(err)
+		return nil, err
+	}
+	policy := &Policy{AccountID: accountID, MerchantID: acc.MerchantID, admin: acc.IsAdmin, grants: make([]grant, 0), apps: make([]*app.Model, 0), apis: make([]collections.APIInfo, 0)}
+	if acc.IsAdmin {
+		policy.AccessLevel = collections.LevelAdmin
+		apps, err := app.FindAll(ctx, a.store, acc.MerchantID)
+		if err != nil {
+			logCtx.Error(err)
+			return nil, err
+		}
+		policy.setApps(apps)
+	}
+	if len(acc.Roles) == 0 {
+		return policy, nil
+	}
+
+	roles, err := role.FindByNames(ctx, a.store, acc.MerchantID, acc.Roles)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+	actions := make(map[string]actionSet)
+	appIDs := make([]string, 0)
+	seen := make(map[string]bool)
+	addApp := func(id string) {
+		if id == "" || seen[id] || !primitive.IsValidObjectID(id) {
+			return
+		}
+		seen[id] = true
+		appIDs = append(appIDs, id)
+	}
+	for _, r := range roles {
+		if r.Level > policy.AccessLevel {
+			policy.AccessLevel = r.Level
+		}
+		for _, id := range r.Apps {
+			addApp(id)
+		}
+		for _, p := range r.Permissions {
+			actions[cleanPath(p.Path)] |= actionsOf(p)
+			addApp(p.AppID)
+		}
+	}
+	for path, set := range actions {
+		policy.grants = append(policy.grants, grant{path: path, actions: set})
+	}
+	sort.Slice(policy.grants, func(i, j int) bool {
+		return policy.grants[i].path < policy.grants[j].path
+	})
+
+	if acc.IsAdmin || len(appIDs) == 0 {
+		return policy, nil
+	}
+	apps, err := app.FindByIDs(ctx, a.store, appIDs)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+	policy.setApps(apps)
+	return policy, nil
+}
+
+// Scope 返回账号的访问范围
+func (p *Policy) Scope() collections.Scope {
+	return collections.Scope{MerchantID: p.MerchantID, AccessLevel: p.AccessLevel, AccountID: p.AccountID}
+}
+
+// setApps 设置应用以及应用的接口
+func (p *Policy) setApps(apps []*app.Model) {
+	p.apps = apps
+	p.apis = make([]collections.APIInfo, 0)
+	for _, a := range apps {
+		p.apis = append(p.apis, a.AccessAPI...)
+	}
+}
@@ -0,0 +1,188 @@
+package permission_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/r2day/collections"
+	"github.com/r2day/collections/auth/account"
+	"github.com/r2day/collections/auth/app"
+	"github.com/r2day/collections/auth/permission"
+	"github.com/r2day/collections/auth/role"
+	cerrors "github.com/r2day/collections/errors"
+	"github.com/r2day/collections/memory"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// setupPermission 在存储后端中创建应用、角色以及账号
+// 返回角色id
+func setupPermission(t *testing.T, store collections.Store) string {
+	t.Helper()
+	ctx := as(merchantA)
+	a := &app.Model{Name: "订单", Order: 1, AccessAPI: []collections.APIInfo{
+		{Path: "/orders", Name: "订单列表", CanViewDetail: true, Order: 2},
+		{Path: "/orders/stats", Name: "订单统计", Order: 1},
+		{Path: "/orders/export", Name: "导出", HideOnSidebar: true},
+		{Path: "/orders/archive", Name: "归档", Disable: true},
+	}}
+	appID, err := app.Repo.UsingStore(store).Create(ctx, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &role.Model{Name: "cashier", Level: collections.LevelStaff, Permissions: []role.PermissionsModel{
+		{AppID: appID, Path: "/orders", Read: true, Detail: true},
+		{AppID: appID, Path: "/orders/stats", Read: true, Detail: true},
+		{AppID: appID, Path: "/orders/export", Read: true},
+		{AppID: appID, Path: "/orders/archive", Read: true},
+	}}
+	r.Apps = []string{appID}
+	roleID, err := role.Repo.UsingStore(store).Create(ctx, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc := &account.Model{Phone: "13800138000", Name: "cashier", Roles: []string{"cashier"}}
+	acc.AccountID = "acc-1"
+	acc.Status = true
+	if _, err := account.Repo.UsingStore(store).Create(ctx, acc); err != nil {
+		t.Fatal(err)
+	}
+	return roleID
+}
+
+func TestPermission(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	setupPermission(t, store)
+	authorizer := permission.For(store)
+
+	cases := []struct {
+		path   string
+		action permission.Action
+		allow  bool
+	}{
+		{"/orders", permission.Read, true},
+		{"/orders", permission.Detail, true},
+		{"/orders", permission.Delete, false},
+		{"/orders/stats", permission.Read, true},
+		{"/orders/stats", permission.Detail, false},
+		{"/orders/archive", permission.Read, false},
+		{"/members", permission.Read, false},
+	}
+	for _, c := range cases {
+		allow, err := authorizer.Authorize(ctx, "acc-1", c.path, c.action)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allow != c.allow {
+			t.Fatalf("%s %s: expected %v", c.action, c.path, c.allow)
+		}
+	}
+
+	policy, err := authorizer.PolicyOf(ctx, "acc-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scope := policy.Scope(); scope.MerchantID != merchantA.MerchantID || scope.AccessLevel != collections.LevelStaff {
+		t.Fatalf("unexpected scope %+v", scope)
+	}
+
+	menu, err := authorizer.Menu(ctx, "acc-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(menu) != 1 || menu[0].Name != "订单" {
+		t.Fatalf("unexpected menu %+v", menu)
+	}
+	paths := make([]string, 0)
+	for _, item := range menu[0].Children {
+		paths = append(paths, item.Path)
+	}
+	if !equal(paths, []string{"/orders/stats", "/orders"}) {
+		t.Fatalf("unexpected menu items %v", paths)
+	}
+
+	_, err = authorizer.Authorize(ctx, "missing", "/orders", permission.Read)
+	expectError(t, err, cerrors.ErrNotFound)
+
+	// 停用的账号没有任何权限
+	disabled := &account.Model{Phone: "13800138001", Roles: []string{"cashier"}}
+	disabled.AccountID = "acc-disabled"
+	if _, err := account.Repo.UsingStore(store).Create(as(merchantA), disabled); err != nil {
+		t.Fatal(err)
+	}
+	_, err = authorizer.Authorize(ctx, "acc-disabled", "/orders", permission.Read)
+	expectError(t, err, cerrors.ErrDisabled)
+
+	// 管理员只能看到自己商户的应用
+	if _, err := app.Repo.UsingStore(store).Create(as(merchantB), &app.Model{Name: "其他商户", AccessAPI: []collections.APIInfo{{Path: "/members", Name: "会员"}}}); err != nil {
+		t.Fatal(err)
+	}
+	admin := &account.Model{Phone: "13800138002", IsAdmin: true}
+	admin.AccountID = "acc-admin"
+	admin.Status = true
+	if _, err := account.Repo.UsingStore(store).Create(as(merchantA), admin); err != nil {
+		t.Fatal(err)
+	}
+	menu, err = authorizer.Menu(ctx, "acc-admin")
+	if err != nil || len(menu) != 1 || menu[0].Name != "订单" {
+		t.Fatalf("admins should only see apps of their merchant, got %+v %v", menu, err)
+	}
+}
+
+func TestPermissionCachePerStore(t *testing.T) {
+	ctx := context.Background()
+	storeA, storeB := memory.NewStore(), memory.NewStore()
+	roleID := setupPermission(t, storeA)
+	setupPermission(t, storeB)
+	a, b := permission.For(storeA), permission.For(storeB)
+	if permission.For(storeA) != a {
+		t.Fatal("the same store should return the same authorizer")
+	}
+	allowed := func(authorizer *permission.Authorizer) bool {
+		t.Helper()
+		allow, err := authorizer.Authorize(ctx, "acc-1", "/orders", permission.Read)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return allow
+	}
+	if !allowed(a) || !allowed(b) {
+		t.Fatal("both stores should allow reading orders")
+	}
+
+	// 绕过数据仓库修改的记录在缓存有效期内不生效
+	objID, _ := collections.ObjectIDFromHex(roleID)
+	_, err := storeA.Collection(role.Repo.CollectionName()).UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: objID}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "permissions", Value: bson.A{}}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 其他存储后端的修改不会清除缓存
+	if err := role.Repo.UsingStore(storeB).Patch(as(merchantA), merchantA, roleIDOf(t, storeB), collections.Patch{Set: bson.M{"desc": "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !allowed(a) {
+		t.Fatal("a write to another store should keep the cache")
+	}
+	// 通过数据仓库修改后立即生效
+	if err := role.Repo.UsingStore(storeA).Patch(as(merchantA), merchantA, roleID, collections.Patch{Set: bson.M{"desc": "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if allowed(a) {
+		t.Fatal("a write to the store should clear its cache")
+	}
+	if !allowed(b) {
+		t.Fatal("the other store should not be affected")
+	}
+}
+
+// roleIDOf 返回存储后端中的角色id
+func roleIDOf(t *testing.T, store collections.Store) string {
+	t.Helper()
+	roles, err := role.FindByNames(context.Background(), store, merchantA.MerchantID, []string{"cashier"})
+	if err != nil || len(roles) != 1 {
+		t.Fatalf("find role: %v", err)
+	}
+	return roles[0].ID.Hex()
+}
@@ -0,0 +1,18 @@
+package role_test
+
+import (
+	"context"
+
+	"github.com/r2day/collections"
+)
+
+// 测试使用的商户以及访问范围
+var (
+	merchantA = collections.Scope{MerchantID: "m-a", AccessLevel: collections.LevelAdmin, AccountID: "acc-a"}
+	merchantB = collections.Scope{MerchantID: "m-b", AccessLevel: collections.LevelAdmin, AccountID: "acc-b"}
+)
+
+// as 返回包含操作者访问范围的 context
+func as(scope collections.Scope) context.Context {
+	return collections.WithScope(context.Background(), scope)
+}
@@ -2,232 +2,138 @@ package role
 
 import (
 	"context"
-	"time"
-
-	rtime "github.com/r2day/base/time"
-	"github.com/r2day/db"
-	"github.com/r2day/rest"
-	log "github.com/sirupsen/logrus"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"github.com/r2day/collections"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// ResourceName 返回资源名称
-func (m *Model) ResourceName() string {
-	return modelName
-}
-
-// CollectionName 返回表名称
-func (m *Model) CollectionName() string {
-	return collectionNamePrefix + modelName + collectionNameSuffix
-}
+// Repo 角色数据仓库
+// level 不能超过操作者的访问级别，按名称以及引用的应用查询
+var Repo = collections.NewRepository[Model](
+	collectionNamePrefix+modelName+collectionNameSuffix, modelName,
+	collections.WithSortableFields("name"),
+	collections.WithFilterFields(collections.StringFilter("name"), collections.StringFilter("apps")),
+	collections.WithRules(
+		collections.Required("name"),
+	),
+	collections.WithLevelFields("level"),
+	collections.WithIndexes(
+		collections.NewIndex("merchant_id", "name"),
+	),
+)
 
 // Create 创建
 // create	POST http://my.api.url/posts
+// 根据所选应用的接口展开权限
 func (m *Model) Create(ctx context.Context) (string, error) {
-	coll := db.MDB.Collection(m.CollectionName())
-
-	// 保存时间设定
-	m.CreatedAt = rtime.FomratTimeAsReader(time.Now().Unix())
-	// 更新时间设定
-	m.UpdatedAt = rtime.FomratTimeAsReader(time.Now().Unix())
-
-	// 插入记录
-	result, err := coll.InsertOne(ctx, m)
-	if err != nil {
-		log.WithField("m", m).Error(err)
+	if m.ID.IsZero() {
+		m.ID = primitive.NewObjectID()
+	}
+	if err := m.syncApps(ctx, Repo.Store(), nil); err != nil {
 		return "", err
 	}
-	stringObjectID := result.InsertedID.(primitive.ObjectID).Hex()
-	return stringObjectID, nil
+	return Repo.Create(ctx, m)
 }
 
-// Delete 删除
-// delete	DELETE http://my.api.url/posts/123
-func (m *Model) Delete(ctx context.Context, id string) error {
-	// TODO result using custom struct instead of bson.M
-	// because you should avoid to export something to customers
-	logCtx := log.WithField("id", id)
-	coll := db.MDB.Collection(m.CollectionName())
-	objID, _ := primitive.ObjectIDFromHex(id)
-	filter := bson.D{{Key: "_id", Value: objID}}
-	// 执行删除
-	result, err := coll.DeleteOne(ctx, filter)
-
-	if err != nil {
-		logCtx.Error(err)
+// Update 整体更新
+// update	PUT http://my.api.url/posts/123
+// 根据所选应用的接口展开权限，用户移除的接口不会重新加入；只更新部分字段时使用 Patch
+func (m *Model) Update(ctx context.Context, scope collections.Scope, id string) error {
+	if err := m.sync(ctx, scope, id); err != nil {
 		return err
 	}
+	return Repo.Update(ctx, scope, id, m)
+}
 
-	if result.DeletedCount < 1 {
-		logCtx.Warning("result.DeletedCount < 1")
-		return nil
+// Patch 局部更新
+// patch	PATCH http://my.api.url/posts/123
+func (m *Model) Patch(ctx context.Context, scope collections.Scope, id string, patch collections.Patch) error {
+	if err := Repo.Patch(ctx, scope, id, patch); err != nil {
+		return err
 	}
-	return nil
+	return m.resyncIf(ctx, scope, patchFields(patch), id)
 }
 
-// GetOne 详情
-// getOne	GET http://my.api.url/posts/123
-func (m *Model) GetOne(ctx context.Context, id string) (*Model, error) {
-	// TODO result using custom struct instead of bson.M
-	// because you should avoid to export something to customers
-	coll := db.MDB.Collection(m.CollectionName())
-	// 绑定查询结果
-	result := &Model{}
-	objID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		log.Error(err)
-		return nil, err
+// UpdateIfVersion 版本号一致时更新
+// 记录已经被其他人修改时返回 errors.ErrConflict
+func (m *Model) UpdateIfVersion(ctx context.Context, scope collections.Scope, id string, version int64) error {
+	if err := m.sync(ctx, scope, id); err != nil {
+		return err
 	}
+	return Repo.UpdateIfVersion(ctx, scope, id, version, m)
+}
 
-	filter := bson.D{{Key: "_id", Value: objID}}
-	logCtx := log.WithField("filter", filter)
-
-	err = coll.FindOne(ctx, filter).Decode(&result)
-	if err != nil {
-		logCtx.Error(err)
-		return nil, err
+// PatchIfVersion 版本号一致时局部更新
+func (m *Model) PatchIfVersion(ctx context.Context, scope collections.Scope, id string, version int64, patch collections.Patch) error {
+	if err := Repo.PatchIfVersion(ctx, scope, id, version, patch); err != nil {
+		return err
 	}
-	return result, nil
+	return m.resyncIf(ctx, scope, patchFields(patch), id)
 }
 
-// GetMany 获取条件查询的结果
-// getMany	GET http://my.api.url/posts?filter={"ids":[123,456,789]}
-func (m *Model) GetMany(ctx context.Context, ids []string) ([]*Model, error) {
-	// TODO result using custom struct instead of bson.M
-	// because you should avoid to export something to customers
-	coll := db.MDB.Collection(m.CollectionName())
-	// 绑定查询结果
-	results := make([]*Model, 0)
-	objIds := make([]*primitive.ObjectID, 0)
-	logCtx := log.WithField("ids", ids)
-
-	for _, i := range ids {
-		objID, _ := primitive.ObjectIDFromHex(i)
-		objIds = append(objIds, &objID)
+// UpdateFields 只更新指定的字段，取值来自 m
+func (m *Model) UpdateFields(ctx context.Context, scope collections.Scope, id string, fields ...string) error {
+	if err := Repo.UpdateFields(ctx, scope, id, m, fields...); err != nil {
+		return err
 	}
-	cursor, err := coll.Find(ctx, bson.M{"_id": bson.M{"$in": objIds}})
+	return m.resyncIf(ctx, scope, fields, id)
+}
 
+// UpdateMany 批量更新
+// updateMany	PUT http://my.api.url/posts?filter={"id":[123,456,789]}
+func (m *Model) UpdateMany(ctx context.Context, scope collections.Scope, ids []string, patch collections.Patch) (*collections.BulkResult, error) {
+	result, err := Repo.UpdateMany(ctx, scope, ids, patch)
 	if err != nil {
-		logCtx.Error(err)
-		return nil, err
+		return result, err
 	}
-
-	if err = cursor.All(ctx, &results); err != nil {
-		logCtx.Error(err)
-		return nil, err
+	updated := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !contains(result.NotFoundIDs, id) {
+			updated = append(updated, id)
+		}
 	}
-	return results, nil
+	return result, m.resyncIf(ctx, scope, patchFields(patch), updated...)
 }
 
-// Update 更新
-// update	PUT http://my.api.url/posts/123
-func (m *Model) Update(ctx context.Context, id string) error {
-	coll := db.MDB.Collection(m.CollectionName())
-	objID, _ := primitive.ObjectIDFromHex(id)
-	filter := bson.D{{Key: "_id", Value: objID}}
-	// 设定更新时间
-	m.UpdatedAt = rtime.FomratTimeAsReader(time.Now().Unix())
-
-	result, err := coll.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: m}})
+// sync 更新前根据已保存的同步记录展开权限
+func (m *Model) sync(ctx context.Context, scope collections.Scope, id string) error {
+	existing, err := Repo.GetOne(ctx, scope, id)
 	if err != nil {
-		log.WithField("id", id).Error(err)
 		return err
 	}
+	m.ID = existing.ID
+	return m.syncApps(ctx, Repo.Store(), existing.AppAPIs)
+}
 
-	if result.MatchedCount < 1 {
-		log.WithField("id", id).Warning("no matched record")
+// resyncIf 局部更新修改了应用或者权限时重新展开权限
+func (m *Model) resyncIf(ctx context.Context, scope collections.Scope, fields []string, ids ...string) error {
+	if !touchesApps(fields) {
 		return nil
 	}
-
+	for _, id := range ids {
+		if err := resyncByID(ctx, scope, id); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// GetList 获取列表
-// getList	GET http://my.api.url/posts?sort=["title","ASC"]&range=[0, 24]&filter={"title":"bar"}
-func (m *Model) GetList(ctx context.Context, merchantID string, accountID string, urlParams *rest.UrlParams) ([]*Model, int64, error) {
-	coll := db.MDB.Collection(m.CollectionName())
-	// 声明需要返回的列表
-	results := make([]*Model, 0)
-	// 声明日志基本信息
-	logCtx := log.WithField("merchantID", merchantID).WithField("urlParams.FilterMap", urlParams.FilterMap)
-	// 声明数据库过滤器
-	// 定义基本过滤规则
-	// 以商户id为基本命名空间
-	// 并且只能看到小于等于自己的级别的数据
-	filters := bson.D{{Key: "merchant_id", Value: merchantID}, {"access_level", bson.D{{"$lte", m.AccessLevel}}}}
-	// 添加更多过滤器
-	// 根据用户规则进行筛选
-	for key, val := range urlParams.FilterMap {
-		// 判断是否是通过id查询
-		// 则进行转换
-		// 一般对应于 ReferenceArrayInput 和 ReferenceManyField
-		if m.ResourceName() == key || key == "id" {
-			// string to array
-			results, err := m.GetMany(ctx, val)
-			if err != nil {
-				logCtx.Error(err)
-				return nil, 0, err
-			}
-			logCtx.WithField("results", results).Warning("is reference request")
-			return results, int64(len(results)), nil
+// FindByNames 查找商户下指定名称或者id的角色
+// 账号的 Roles 中保存的是角色名称，兼容保存角色id的历史数据；store 为空时使用默认的存储后端
+func FindByNames(ctx context.Context, store collections.Store, merchantID string, names []string) ([]*Model, error) {
+	or := bson.A{bson.D{{Key: "name", Value: bson.D{{Key: "$in", Value: names}}}}}
+	ids := make([]primitive.ObjectID, 0, len(names))
+	for _, name := range names {
+		if id, err := primitive.ObjectIDFromHex(name); err == nil {
+			ids = append(ids, id)
 		}
-
-		// 用户可以指定accountId
-		bm := bson.E{Key: key, Value: val}
-		filters = append(filters, bm)
-
-	}
-
-	// 添加状态过滤器
-	if urlParams.HasFilter {
-		filterByStatus := bson.E{Key: "status", Value: urlParams.FilterCommon.Status}
-		filters = append(filters, filterByStatus)
 	}
-
-	logCtx.WithField("filters", filters).Debug("final filters has been combine")
-	// 获取总数（含过滤规则）
-	totalCounter, err := coll.CountDocuments(context.TODO(), filters)
-	if err == mongo.ErrNoDocuments {
-		logCtx.Error(err)
-		return nil, 0, err
+	if len(ids) > 0 {
+		or = append(or, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}})
 	}
-	if err != nil {
-		logCtx.Error(err)
-		return nil, 0, err
-	}
-
-	// 进行必要分页处理
-	opt := options.Find()
-	// 排序方式
-	if urlParams.Sort.SortType == rest.AES {
-		opt.SetSort(bson.M{urlParams.Sort.Key: -1})
-	} else {
-		opt.SetSort(bson.M{urlParams.Sort.Key: 1})
-	}
-
-	opt.SetSkip(int64(urlParams.Range.Offset))
-	opt.SetLimit(int64(urlParams.Range.Limit))
-
-	// 获取数据列表
-	cursor, err := coll.Find(ctx, filters, opt)
-	if err == mongo.ErrNoDocuments {
-		logCtx.Error(err)
-		return nil, totalCounter, err
-	}
-
-	if err != nil {
-		logCtx.Error(err)
-		return nil, totalCounter, err
-	}
-
-	if err = cursor.All(context.TODO(), &results); err != nil {
-		logCtx.Error(err)
-		return nil, totalCounter, err
-	}
-	return results, totalCounter, nil
-
+	return Repo.UsingStore(store).Find(ctx, bson.D{
+		{Key: "merchant_id", Value: merchantID},
+		{Key: "$or", Value: or},
+	})
 }
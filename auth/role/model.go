@@ -1,7 +1,7 @@
 package role
 
 import (
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"github.com/r2day/collections"
 )
 
 const (
@@ -25,36 +25,27 @@ const (
 // Model 模型
 type Model struct {
 	// 基本的数据库模型字段，一般情况所有model都应该包含如下字段
-	// 创建时（用户上传的数据为空，所以默认可以不传该值)
-	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	// 商户号
-	MerchantID string `json:"merchant_id" bson:"merchant_id"`
-	// 创建者
-	AccountID string `json:"account_id" bson:"account_id"`
-	// 创建时间
-	CreatedAt string `json:"created_at" bson:"created_at"`
-	// 更新时间
-	UpdatedAt string `json:"updated_at" bson:"updated_at"`
-	// 状态
-	Status bool `json:"status"`
+	collections.BaseModel `bson:",inline"`
 	// 名称
 	Name string `json:"name" bson:"name"`
 	// 描述
 	Desc string `json:"desc" bson:"desc"`
 	// 图片
 	Image string `json:"image" bson:"image"`
+	// 访问级别
+	// 账号的访问级别为其所有角色中最高的级别，不能超过创建者的级别
+	Level collections.AccessLevel `json:"level" bson:"level"`
 	// 应用列表
 	// 存储应用的id
 	// 通过应用id 快速获得应用列表
 	Apps []string `json:"apps" bson:"apps"`
-	// AccessApi 可访问的api列表
-	// 即将废弃
-	// AccessAPI []collections.APIInfo `json:"access_api"  bson:"access_api"`
-	// 根据角色的最低级别写入
-	AccessLevel uint `json:"access_level" bson:"access_level"`
 
-	// 图片
+	// 权限列表
+	// 创建以及更新时根据所选应用的接口自动展开，用户可以移除其中的接口
 	Permissions []PermissionsModel `json:"permissions" bson:"permissions"`
+	// 上次同步时各应用的接口路径
+	// 用于区分用户移除的接口以及应用新增的接口
+	AppAPIs map[string][]string `json:"-" bson:"app_apis"`
 }
 
 // PermissionsModel 模型
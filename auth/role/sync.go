@@ -0,0 +1,254 @@
+package role
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/r2day/collections"
+	"github.com/r2day/collections/auth/app"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Propagation 应用新增接口后同步到角色的方式
+type Propagation int
+
+const (
+	// PropagateNone 不同步，新接口需要在角色中手动添加
+	PropagateNone Propagation = iota
+	// PropagateReadOnly 同步为只读权限（读以及详情）
+	PropagateReadOnly
+	// PropagateAll 同步为全部权限
+	PropagateAll
+)
+
+// NewAPIPropagation 应用新增接口后同步到已选择该应用的角色的方式
+// 新选择的应用总是展开为全部权限
+var NewAPIPropagation = PropagateReadOnly
+
+// syncFields 同步时更新的字段
+var syncFields = []string{"apps", "permissions", "app_apis"}
+
+func init() {
+	// 应用修改后同步到写入的存储后端中同一商户下选择了这些应用的角色
+	// 应用已经写入，同步失败只记录日志，可以通过 SyncApps 重新同步
+	collections.OnChange(app.Repo.CollectionName(), func(ctx context.Context, change collections.Change) error {
+		logCtx := log.WithField("collection", change.Collection).WithField("merchantID", change.MerchantID).WithField("ids", change.IDs)
+		if change.MerchantID == "" {
+			logCtx.Warning("unknown merchant, roles are not synced")
+			return nil
+		}
+		result, err := SyncApps(ctx, change.Store, change.MerchantID, change.IDs...)
+		if err != nil {
+			logCtx.Error(err)
+			return nil
+		}
+		if len(result.Failed) > 0 {
+			logCtx.WithField("failed", result.Failed).Error("failed to sync roles")
+		}
+		return nil
+	})
+}
+
+// SyncResult 同步结果
+type SyncResult struct {
+	// 权限发生变化的角色数
+	Updated int64
+	// 权限没有变化的角色数
+	Unchanged int64
+	// 同步失败的角色id
+	Failed []string
+}
+
+// SyncApps 将存储后端中应用的接口同步到商户下选择了这些应用的角色
+// store 为空时使用默认的存储后端，merchantID 为空时同步所有商户，appIDs 为空时同步所有角色；应用修改后自动调用
+// 用户移除的接口不会重新加入，应用新增的接口按照 NewAPIPropagation 加入，应用删除的接口从角色中移除
+func SyncApps(ctx context.Context, store collections.Store, merchantID string, appIDs ...string) (*SyncResult, error) {
+	result := &SyncResult{Failed: make([]string, 0)}
+	filter := bson.D{}
+	if merchantID != "" {
+		filter = append(filter, bson.E{Key: "merchant_id", Value: merchantID})
+	}
+	if len(appIDs) > 0 {
+		filter = append(filter, bson.E{Key: "apps", Value: bson.D{{Key: "$in", Value: appIDs}}})
+	}
+	repo := Repo.UsingStore(store)
+	roles, err := repo.Find(ctx, filter)
+	if err != nil {
+		return result, err
+	}
+	for _, r := range roles {
+		if len(r.Apps) == 0 && len(r.AppAPIs) == 0 {
+			continue
+		}
+		changed, err := r.resync(ctx, repo)
+		switch {
+		case err != nil:
+			log.WithField("id", r.ID.Hex()).Error(err)
+			result.Failed = append(result.Failed, r.ID.Hex())
+		case changed:
+			result.Updated++
+		default:
+			result.Unchanged++
+		}
+	}
+	return result, nil
+}
+
+// resyncByID 重新同步指定角色的权限
+func resyncByID(ctx context.Context, scope collections.Scope, id string) error {
+	r, err := Repo.GetOne(ctx, scope, id)
+	if err != nil {
+		return err
+	}
+	_, err = r.resync(ctx, Repo)
+	return err
+}
+
+// resync 重新同步已保存在数据仓库中的角色的权限
+// 版本号不一致时返回 errors.ErrConflict
+func (m *Model) resync(ctx context.Context, repo *collections.Repository[Model, *Model]) (bool, error) {
+	before := []interface{}{m.Apps, m.Permissions, m.AppAPIs}
+	if err := m.syncApps(ctx, repo.Store(), m.AppAPIs); err != nil {
+		return false, err
+	}
+	if reflect.DeepEqual(before, []interface{}{m.Apps, m.Permissions, m.AppAPIs}) {
+		return false, nil
+	}
+	scope := collections.Scope{MerchantID: m.MerchantID, AccessLevel: m.AccessLevel}
+	patch := collections.Patch{Set: bson.M{
+		syncFields[0]: m.Apps,
+		syncFields[1]: m.Permissions,
+		syncFields[2]: m.AppAPIs,
+	}}
+	// 同步由系统执行，以角色自身的访问范围更新
+	if err := repo.PatchIfVersion(collections.WithScope(ctx, scope), scope, m.ID.Hex(), m.Version, patch); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// syncApps 读取存储后端中所选的应用并展开权限
+// known 为上次同步时各应用的接口路径
+func (m *Model) syncApps(ctx context.Context, store collections.Store, known map[string][]string) error {
+	apps := make(map[string]*app.Model)
+	if len(m.Apps) > 0 {
+		found, err := app.FindByIDs(ctx, store, m.Apps)
+		if err != nil {
+			return err
+		}
+		for _, a := range found {
+			apps[a.ID.Hex()] = a
+		}
+	}
+	m.expand(apps, known)
+	return nil
+}
+
+// expand 根据应用的接口展开权限
+// 已有的权限保持不变；新选择的应用展开为全部权限；
+// 已同步过的应用中，上次同步时存在但是角色中没有的接口视为用户移除，新增的接口按照 NewAPIPropagation 加入
+// 已经不存在或者取消选择的应用的权限会被移除，没有应用编号的权限为手动添加，保持不变
+func (m *Model) expand(apps map[string]*app.Model, known map[string][]string) {
+	selected := make([]string, 0, len(m.Apps))
+	for _, id := range m.Apps {
+		if _, ok := apps[id]; ok && !contains(selected, id) {
+			selected = append(selected, id)
+		}
+	}
+
+	existing := make(map[string]map[string]PermissionsModel)
+	permissions := make([]PermissionsModel, 0, len(m.Permissions))
+	for _, p := range m.Permissions {
+		if p.AppID == "" {
+			permissions = append(permissions, p)
+			continue
+		}
+		if existing[p.AppID] == nil {
+			existing[p.AppID] = make(map[string]PermissionsModel)
+		}
+		existing[p.AppID][p.Path] = p
+	}
+
+	synced := make(map[string][]string, len(selected))
+	for _, id := range selected {
+		paths, had := known[id]
+		current := make([]string, 0, len(apps[id].AccessAPI))
+		for _, api := range apps[id].AccessAPI {
+			if contains(current, api.Path) {
+				continue
+			}
+			current = append(current, api.Path)
+			if p, ok := existing[id][api.Path]; ok {
+				permissions = append(permissions, p)
+				continue
+			}
+			propagation := PropagateAll
+			if had {
+				if contains(paths, api.Path) {
+					continue
+				}
+				propagation = NewAPIPropagation
+			}
+			if p, ok := m.permission(id, api.Path, propagation); ok {
+				permissions = append(permissions, p)
+			}
+		}
+		synced[id] = current
+	}
+
+	m.Apps = selected
+	m.Permissions = permissions
+	m.AppAPIs = synced
+}
+
+// permission 按照同步方式返回接口的权限
+func (m *Model) permission(appID string, path string, propagation Propagation) (PermissionsModel, bool) {
+	p := PermissionsModel{AppID: appID, Path: path}
+	if !m.ID.IsZero() {
+		p.RoleID = m.ID.Hex()
+	}
+	switch propagation {
+	case PropagateReadOnly:
+		p.Read, p.Detail = true, true
+	case PropagateAll:
+		p.Read, p.Write, p.Update, p.Detail, p.Delete = true, true, true, true, true
+	default:
+		return p, false
+	}
+	return p, true
+}
+
+// touchesApps 判断是否修改了应用或者权限
+func touchesApps(fields []string) bool {
+	for _, field := range fields {
+		for _, f := range syncFields[:2] {
+			if field == f || strings.HasPrefix(field, f+".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// patchFields 返回局部更新修改的字段
+func patchFields(patch collections.Patch) []string {
+	fields := append([]string{}, patch.Unset...)
+	for _, set := range []bson.M{patch.Set, patch.Inc, patch.Push, patch.Pull} {
+		for key := range set {
+			fields = append(fields, key)
+		}
+	}
+	return fields
+}
+
+// contains 判断列表中是否包含指定的值
+func contains(list []string, val string) bool {
+	for _, v := range list {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
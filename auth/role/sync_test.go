@@ -0,0 +1,84 @@
+package role_test
+
+import (
+	"testing"
+
+	"github.com/r2day/collections"
+	"github.com/r2day/collections/auth/app"
+	"github.com/r2day/collections/auth/role"
+	"github.com/r2day/collections/memory"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRoleSyncOnAppChange(t *testing.T) {
+	// 默认的存储后端没有设置数据库，同步只能使用写入的存储后端
+	collections.SetDefaultStore(nil)
+	store := memory.NewStore()
+	ctx := as(merchantA)
+	apps, roles := app.Repo.UsingStore(store), role.Repo.UsingStore(store)
+
+	appID, err := apps.Create(ctx, &app.Model{Name: "订单", AccessAPI: []collections.APIInfo{
+		{Path: "/orders", Name: "订单列表"},
+		{Path: "/orders/stats", Name: "订单统计"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	roleID, err := roles.Create(ctx, &role.Model{Name: "cashier", Apps: []string{appID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 其他商户的角色不会被同步
+	otherID, err := roles.Create(as(merchantB), &role.Model{Name: "cashier", Apps: []string{appID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := role.SyncApps(ctx, store, merchantA.MerchantID, appID)
+	if err != nil || result.Updated != 1 {
+		t.Fatalf("expected 1 updated role, got %+v %v", result, err)
+	}
+	permissions := func() map[string]role.PermissionsModel {
+		t.Helper()
+		r, err := roles.GetOne(as(merchantA), merchantA, roleID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := make(map[string]role.PermissionsModel)
+		for _, p := range r.Permissions {
+			found[p.Path] = p
+		}
+		return found
+	}
+	if p := permissions(); len(p) != 2 || !p["/orders"].Delete {
+		t.Fatalf("a new app should expand to full permissions, got %+v", p)
+	}
+
+	// 用户移除的接口不会重新加入
+	kept := []role.PermissionsModel{permissions()["/orders"]}
+	if err := roles.Patch(as(merchantA), merchantA, roleID, collections.Patch{Set: bson.M{"permissions": kept}}); err != nil {
+		t.Fatal(err)
+	}
+	// 应用新增的接口按照 NewAPIPropagation 加入
+	err = apps.Patch(as(merchantA), merchantA, appID, collections.Patch{Push: bson.M{
+		"access_api": collections.APIInfo{Path: "/orders/refund", Name: "退款"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := permissions()
+	if len(p) != 2 || p["/orders/stats"].Path != "" {
+		t.Fatalf("a removed api should stay removed, got %+v", p)
+	}
+	if refund := p["/orders/refund"]; !refund.Read || refund.Delete {
+		t.Fatalf("a new api should be read only, got %+v", refund)
+	}
+	other, err := roles.GetOne(as(merchantB), merchantB, otherID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range other.Permissions {
+		if p.Path == "/orders/refund" {
+			t.Fatalf("roles of other merchants should not be synced, got %+v", other.Permissions)
+		}
+	}
+}
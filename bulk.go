@@ -0,0 +1,134 @@
+package collections
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BulkResult 批量操作结果
+type BulkResult struct {
+	// 满足条件的记录数
+	MatchedCount int64
+	// 被修改的记录数
+	ModifiedCount int64
+	// 被删除的记录数
+	DeletedCount int64
+	// 不存在或者不在访问范围内的id
+	NotFoundIDs []string
+}
+
+// notFoundIDs 返回访问范围内不存在的id
+func (r *Repository[T, PT]) notFoundIDs(ctx context.Context, filter bson.D, ids []string) ([]string, error) {
+	raws, err := r.collection().Find(ctx, filter, &FindOptions{Projection: bson.D{{Key: idField, Value: 1}}})
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool, len(raws))
+	for _, raw := range raws {
+		if id, ok := raw.Lookup(idField).ObjectIDOK(); ok {
+			found[id.Hex()] = true
+		}
+	}
+	missing := make([]string, 0)
+	for _, id := range ids {
+		objID, _ := primitive.ObjectIDFromHex(id)
+		if !found[objID.Hex()] {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
+
+// UpdateMany 批量更新
+// updateMany	PUT http://my.api.url/posts?filter={"id":[123,456,789]}
+// 只更新访问范围内的记录，例如 Patch{Set: bson.M{"enables.is_open": false}}
+func (r *Repository[T, PT]) UpdateMany(ctx context.Context, scope Scope, ids []string, patch Patch) (*BulkResult, error) {
+	logCtx := log.WithField("merchantID", scope.MerchantID).WithField("ids", ids)
+	scope, err := actorScope(ctx, scope)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+	patch, err = r.checkPatch(patch)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+	if err := r.checkPatchLevels(scope, patch); err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+	filter, err := r.filterByIDs(scope, ids)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return &BulkResult{NotFoundIDs: []string{}}, nil
+	}
+
+	missing, err := r.notFoundIDs(ctx, filter, ids)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+	if patch.Set, err = r.hashPatch(patch.Set, nil); err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+	guards, err := r.checkCurrency(ctx, filter, patch)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+
+	result, err := r.collection().UpdateMany(ctx, append(filter, guards...), patch.update())
+	if err != nil {
+		logCtx.Error(err)
+		return nil, r.duplicateError(err)
+	}
+	return &BulkResult{
+		MatchedCount:  result.MatchedCount,
+		ModifiedCount: result.ModifiedCount,
+		NotFoundIDs:   missing,
+	}, nil
+}
+
+// DeleteMany 批量删除
+// deleteMany	DELETE http://my.api.url/posts?filter={"id":[123,456,789]}
+// 只删除访问范围内的记录，开启软删除时只标记删除
+func (r *Repository[T, PT]) DeleteMany(ctx context.Context, scope Scope, ids []string) (*BulkResult, error) {
+	logCtx := log.WithField("merchantID", scope.MerchantID).WithField("ids", ids)
+	scope, err := actorScope(ctx, scope)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+	filter, err := r.filterByIDs(scope, ids)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return &BulkResult{NotFoundIDs: []string{}}, nil
+	}
+
+	missing, err := r.notFoundIDs(ctx, filter, ids)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+	deleted, err := r.deleteMany(ctx, scope, filter)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+	return &BulkResult{
+		MatchedCount: deleted,
+		DeletedCount: deleted,
+		NotFoundIDs:  missing,
+	}, nil
+}
@@ -0,0 +1,54 @@
+package collections_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/r2day/collections"
+	cerrors "github.com/r2day/collections/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBulk(t *testing.T) {
+	repo := newRepo()
+	id1 := create(t, repo, merchantA, &item{Name: "1"})
+	id2 := create(t, repo, merchantA, &item{Name: "2"})
+	idB := create(t, repo, merchantB, &item{Name: "b"})
+
+	result, err := repo.UpdateMany(as(merchantA), merchantA, []string{id1, id2, idB}, collections.Patch{Set: bson.M{"qty": 7}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.MatchedCount != 2 || result.ModifiedCount != 2 || !equal(result.NotFoundIDs, []string{idB}) {
+		t.Fatalf("unexpected update result %+v", result)
+	}
+	b, err := repo.GetOne(as(merchantB), merchantB, idB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Qty != 0 {
+		t.Fatal("other merchant's record should not be updated")
+	}
+
+	// 金额累加的币种需要与记录一致
+	idUSD := create(t, repo, merchantA, &item{Name: "usd", Price: collections.MustParseMoney("1", "USD")})
+	_, err = repo.UpdateMany(as(merchantA), merchantA, []string{id1, idUSD}, collections.Patch{Inc: bson.M{"price": collections.MustParseMoney("1", "")}})
+	expectError(t, err, cerrors.ErrValidation)
+	if _, err := repo.UpdateMany(as(merchantA), merchantA, []string{idUSD}, collections.Patch{Inc: bson.M{"price": collections.MustParseMoney("1", "USD")}}); err != nil {
+		t.Fatal(err)
+	}
+	if usd, _ := repo.GetOne(as(merchantA), merchantA, idUSD); usd.Price.Units != 200 {
+		t.Fatalf("unexpected price %+v", usd.Price)
+	}
+
+	result, err = repo.DeleteMany(as(merchantA), merchantA, []string{id1, idB})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.DeletedCount != 1 || !equal(result.NotFoundIDs, []string{idB}) {
+		t.Fatalf("unexpected delete result %+v", result)
+	}
+	if _, err := repo.GetMany(as(merchantA), merchantA, []string{id1, "bad"}); !errors.Is(err, cerrors.ErrInvalidID) {
+		t.Fatalf("invalid id should be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package collections
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Change 数据表的变化
+type Change struct {
+	// 写入的存储后端，通知只应该处理该存储后端中的数据
+	Store Store
+	// 表名称
+	Collection string
+	// 记录所属的商户，无法确定时为空
+	MerchantID string
+	// 发生变化的记录id，无法确定时为空，例如清理已删除的记录
+	// 批量更新时为满足条件的记录，不一定都被修改
+	IDs []string
+}
+
+// ChangeHook 数据表发生变化时调用
+// 返回的错误会作为写入操作的错误返回，此时数据已经写入
+type ChangeHook func(ctx context.Context, change Change) error
+
+var (
+	// changeHooksMu 保护 changeHooks
+	changeHooksMu sync.RWMutex
+	// changeHooks 各数据表的变化通知
+	changeHooks = make(map[string][]ChangeHook)
+)
+
+// OnChange 注册数据表的变化通知
+// 通过数据仓库创建、更新以及删除记录后调用，一般用于清除缓存
+// 所有存储后端的写入都会调用，通知需要根据 Change.Store 处理对应存储后端中的数据
+// 只能感知当前进程内的修改
+func OnChange(collection string, hook ChangeHook) {
+	changeHooksMu.Lock()
+	defer changeHooksMu.Unlock()
+	changeHooks[collection] = append(changeHooks[collection], hook)
+}
+
+// notifyChange 调用数据表的变化通知
+// 所有通知都会调用，返回合并后的错误
+func notifyChange(ctx context.Context, change Change) error {
+	changeHooksMu.RLock()
+	hooks := append([]ChangeHook{}, changeHooks[change.Collection]...)
+	changeHooksMu.RUnlock()
+	errs := make([]error, 0)
+	for _, hook := range hooks {
+		if err := hook(ctx, change); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// changedIDs 返回过滤条件中的记录id
+// 只支持 _id 等于或者 $in 的条件，其他条件返回空
+func changedIDs(filter bson.D) []string {
+	for _, e := range filter {
+		if e.Key != idField {
+			continue
+		}
+		switch v := e.Value.(type) {
+		case primitive.ObjectID:
+			return []string{v.Hex()}
+		case bson.D:
+			if len(v) != 1 || v[0].Key != "$in" {
+				return nil
+			}
+			ids, ok := v[0].Value.([]primitive.ObjectID)
+			if !ok {
+				return nil
+			}
+			hex := make([]string, 0, len(ids))
+			for _, id := range ids {
+				hex = append(hex, id.Hex())
+			}
+			return hex
+		}
+	}
+	return nil
+}
+
+// changedMerchant 返回写入的记录或者过滤条件中的商户号
+func changedMerchant(doc interface{}) string {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return ""
+	}
+	merchantID, _ := bson.Raw(data).Lookup("merchant_id").StringValueOK()
+	return merchantID
+}
+
+// notifyingCollection 写入成功后调用变化通知的数据表
+type notifyingCollection struct {
+	Collection
+	store Store
+	name  string
+}
+
+// notify 调用变化通知
+// doc 为写入的记录或者过滤条件，用于确定商户号
+func (c notifyingCollection) notify(ctx context.Context, doc interface{}, ids []string) error {
+	return notifyChange(ctx, Change{Store: c.store, Collection: c.name, MerchantID: changedMerchant(doc), IDs: ids})
+}
+
+// InsertOne 插入一条记录
+func (c notifyingCollection) InsertOne(ctx context.Context, doc interface{}) (*mongo.InsertOneResult, error) {
+	result, err := c.Collection.InsertOne(ctx, doc)
+	if err != nil {
+		return result, err
+	}
+	ids := make([]string, 0, 1)
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		ids = append(ids, id.Hex())
+	}
+	return result, c.notify(ctx, doc, ids)
+}
+
+// UpdateOne 更新一条记录
+func (c notifyingCollection) UpdateOne(ctx context.Context, filter bson.D, update bson.D) (*mongo.UpdateResult, error) {
+	result, err := c.Collection.UpdateOne(ctx, filter, update)
+	if err != nil || result.MatchedCount == 0 {
+		return result, err
+	}
+	return result, c.notify(ctx, filter, changedIDs(filter))
+}
+
+// UpdateMany 更新所有满足条件的记录
+func (c notifyingCollection) UpdateMany(ctx context.Context, filter bson.D, update bson.D) (*mongo.UpdateResult, error) {
+	result, err := c.Collection.UpdateMany(ctx, filter, update)
+	if err != nil || result.MatchedCount == 0 {
+		return result, err
+	}
+	return result, c.notify(ctx, filter, changedIDs(filter))
+}
+
+// DeleteOne 删除一条记录
+func (c notifyingCollection) DeleteOne(ctx context.Context, filter bson.D) (*mongo.DeleteResult, error) {
+	result, err := c.Collection.DeleteOne(ctx, filter)
+	if err != nil || result.DeletedCount == 0 {
+		return result, err
+	}
+	return result, c.notify(ctx, filter, changedIDs(filter))
+}
+
+// DeleteMany 删除所有满足条件的记录
+func (c notifyingCollection) DeleteMany(ctx context.Context, filter bson.D) (*mongo.DeleteResult, error) {
+	result, err := c.Collection.DeleteMany(ctx, filter)
+	if err != nil || result.DeletedCount == 0 {
+		return result, err
+	}
+	return result, c.notify(ctx, filter, changedIDs(filter))
+}
@@ -0,0 +1,64 @@
+package collections_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/r2day/collections"
+	"github.com/r2day/collections/memory"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestChangeHook(t *testing.T) {
+	store := memory.NewStore()
+	repo := collections.NewRepository[item]("test_changes", "change").UsingStore(store)
+	changes := make([]collections.Change, 0)
+	var hookErr error
+	collections.OnChange("test_changes", func(ctx context.Context, change collections.Change) error {
+		changes = append(changes, change)
+		return hookErr
+	})
+
+	ctx := as(merchantA)
+	a, err := repo.Create(ctx, &item{Name: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := repo.Create(ctx, &item{Name: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.UpdateMany(as(merchantA), merchantA, []string{a, b}, collections.Patch{Set: bson.M{"qty": 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %d", len(changes))
+	}
+	for _, change := range changes {
+		if change.Store != store || change.Collection != "test_changes" {
+			t.Fatalf("unexpected change %+v", change)
+		}
+	}
+	if !equal(changes[0].IDs, []string{a}) || !equal(changes[2].IDs, []string{a, b}) {
+		t.Fatalf("unexpected ids %v %v", changes[0].IDs, changes[2].IDs)
+	}
+
+	// 其他存储后端的写入同样会通知，但是 Store 不同
+	other := memory.NewStore()
+	if _, err := repo.UsingStore(other).Create(ctx, &item{Name: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if changes[3].Store != other {
+		t.Fatal("the change should carry the store that was written")
+	}
+
+	// 通知的错误作为写入的错误返回，记录已经写入
+	hookErr = errors.New("hook failed")
+	err = repo.Patch(as(merchantA), merchantA, a, collections.Patch{Set: bson.M{"name": "a2"}})
+	expectError(t, err, hookErr)
+	found, err := repo.GetOne(as(merchantA), merchantA, a)
+	if err != nil || found.Name != "a2" {
+		t.Fatalf("the patch should be written, got %v %v", found, err)
+	}
+}
@@ -1,7 +1,9 @@
 package order
 
 import (
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+
+	"github.com/r2day/collections"
 )
 
 const (
@@ -32,17 +34,17 @@ type Customer struct {
 // 订单总额	已支付金额	菜品总额	会员卡支付	会员卡积分抵扣金额	已退订/已退款
 type Amounter struct {
 	// 订单总额
-	Amount float64 `json:"amount" bson:"amount"`
+	Amount collections.Money `json:"amount" bson:"amount"`
 	// 已经支付金额
-	Paid float64 `json:"paid" bson:"paid"`
+	Paid collections.Money `json:"paid" bson:"paid"`
 	// 菜品总额
-	Total float64 `json:"total" bson:"total"`
+	Total collections.Money `json:"total" bson:"total"`
 	// 会员卡支付
-	VIP float64 `json:"vip" bson:"vip"`
+	VIP collections.Money `json:"vip" bson:"vip"`
 	// 会员卡积分抵扣金额
-	Deduction float64 `json:"deduction" bson:"deduction"`
+	Deduction collections.Money `json:"deduction" bson:"deduction"`
 	// 已退订/已退款
-	Refund float64 `json:"refund" bson:"refund"`
+	Refund collections.Money `json:"refund" bson:"refund"`
 }
 
 // Model 模型
@@ -51,20 +53,7 @@ type Amounter struct {
 // 已支付金额	菜品总额	会员卡支付	会员卡积分抵扣金额	已退订/已退款	账单备注
 type Model struct {
 	// 基本的数据库模型字段，一般情况所有model都应该包含如下字段
-	// 创建时（用户上传的数据为空，所以默认可以不传该值)
-	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	// 商户号
-	MerchantID string `json:"merchant_id" bson:"merchant_id"`
-	// 创建者
-	AccountID string `json:"account_id" bson:"account_id"`
-	// 创建时间
-	CreatedAt string `json:"created_at" bson:"created_at"`
-	// 更新时间
-	UpdatedAt string `json:"updated_at" bson:"updated_at"`
-	// 状态
-	Status bool `json:"status"`
-	// 根据角色的最低级别写入
-	AccessLevel uint `json:"access_level" bson:"access_level"`
+	collections.BaseModel `bson:",inline"`
 
 	// 序号
 	SerialNumber uint `json:"serial_number" bson:"serial_number"`
@@ -87,7 +76,7 @@ type Model struct {
 	AmountInfo Amounter `json:"amount_info" bson:"amount_info"`
 
 	// 订单时间
-	OrderTime string `json:"order_time" bson:"order_time"`
+	OrderTime time.Time `json:"order_time" bson:"order_time"`
 	// 订单状态
 	OrderStatus string `json:"order_status" bson:"order_status"`
 	// 订单类型
@@ -15,6 +15,10 @@ type APIInfo struct {
 	// 是否在sidebar中隐藏
 	// 默认false， 表示默认不隐藏
 	HideOnSidebar bool `json:"hide_on_sidebar" bson:"hide_on_sidebar"`
+	// 菜单图标
+	Icon string `json:"icon" bson:"icon"`
+	// 菜单中的顺序，从小到大排列，相同时按照声明的顺序
+	Order int `json:"order" bson:"order"`
 }
 
 // Address 地址
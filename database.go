@@ -0,0 +1,24 @@
+package collections
+
+import (
+	"github.com/r2day/db"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// defaultDatabase 默认的数据库
+var defaultDatabase *mongo.Database
+
+// SetDefaultDatabase 设置默认的数据库
+// 一般在启动时调用，未设置时使用 db.MDB
+func SetDefaultDatabase(database *mongo.Database) {
+	defaultDatabase = database
+}
+
+// DefaultDatabase 返回默认的数据库
+// 未设置时使用 db.MDB 以保持兼容
+func DefaultDatabase() *mongo.Database {
+	if defaultDatabase != nil {
+		return defaultDatabase
+	}
+	return db.MDB
+}
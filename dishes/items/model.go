@@ -1,7 +1,7 @@
 package items
 
 import (
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"github.com/r2day/collections"
 )
 
 const (
@@ -47,13 +47,13 @@ type SpecificationPrice struct {
 	// *规格名称
 	Name string `json:"name" bson:"name"`
 	// *常规售价
-	Normal float64 `json:"normal" bson:"normal"`
+	Normal collections.Money `json:"normal" bson:"normal"`
 	// 常规会员价
-	NormalVIP float64 `json:"normal_vip" bson:"normal_vip"`
+	NormalVIP collections.Money `json:"normal_vip" bson:"normal_vip"`
 	// 外卖价格
-	TakeOut float64 `json:"take_out" bson:"take_out"`
+	TakeOut collections.Money `json:"take_out" bson:"take_out"`
 	// 外卖会员价
-	TakeOutVIP float64 `json:"take_out_vip" bson:"take_out_vip"`
+	TakeOutVIP collections.Money `json:"take_out_vip" bson:"take_out_vip"`
 }
 
 // EnablesSwitch 开关
@@ -85,20 +85,7 @@ type EnablesSwitch struct {
 // Model 模型
 type Model struct {
 	// 基本的数据库模型字段，一般情况所有model都应该包含如下字段
-	// 创建时（用户上传的数据为空，所以默认可以不传该值)
-	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	// 商户号
-	MerchantID string `json:"merchant_id" bson:"merchant_id"`
-	// 创建者
-	AccountID string `json:"account_id" bson:"account_id"`
-	// 创建时间
-	CreatedAt string `json:"created_at" bson:"created_at"`
-	// 更新时间
-	UpdatedAt string `json:"updated_at" bson:"updated_at"`
-	// 状态
-	Status bool `json:"status"`
-	// 根据角色的最低级别写入
-	AccessLevel uint `json:"access_level" bson:"access_level"`
+	collections.BaseModel `bson:",inline"`
 
 	// 基本信息
 	BasicInfo BasicInformation `json:"basic_info" bson:"basic_info"`
@@ -0,0 +1,58 @@
+package collections
+
+import (
+	"regexp"
+
+	cerrors "github.com/r2day/collections/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// duplicateIndexPattern 从重复键错误中提取索引名称
+	duplicateIndexPattern = regexp.MustCompile(`index: (\S+) dup key`)
+	// indexKeyPattern 从索引名称中提取字段
+	indexKeyPattern = regexp.MustCompile(`(.+?)_-?1(?:_|$)`)
+)
+
+// scopeFields 唯一索引中用于区分命名空间的字段
+// 不会出现在重复字段中
+var scopeFields = []string{"merchant_id", "access_level", deletedAtField}
+
+// duplicateError 将 mongo 的重复键错误转换为 *errors.DuplicateError
+// 其他错误原样返回
+func (r *Repository[T, PT]) duplicateError(err error) error {
+	if err == nil || !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	match := duplicateIndexPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return &cerrors.DuplicateError{Fields: []string{}}
+	}
+	name := match[1]
+	keys := make([]string, 0)
+	found := false
+	for _, index := range r.Indexes() {
+		if index.Name == name {
+			for _, k := range index.Keys {
+				keys = append(keys, k.Key)
+			}
+			found = true
+			break
+		}
+	}
+	if !found && name == "_id_" {
+		keys = append(keys, idField)
+	} else if !found {
+		for _, m := range indexKeyPattern.FindAllStringSubmatch(name, -1) {
+			keys = append(keys, m[1])
+		}
+	}
+
+	fields := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !contains(scopeFields, key) {
+			fields = append(fields, key)
+		}
+	}
+	return &cerrors.DuplicateError{Index: name, Fields: fields}
+}
@@ -0,0 +1,52 @@
+package collections
+
+import (
+	"errors"
+	"testing"
+
+	cerrors "github.com/r2day/collections/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// duplicateKeyError 返回指定消息的重复键错误
+func duplicateKeyError(message string) error {
+	return mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000, Message: message}}}
+}
+
+func TestDuplicateIndexName(t *testing.T) {
+	index := UniqueIndex("merchant_id", "phone")
+	index.Name = "uniq_phone"
+	repo := NewRepository[BaseModel]("test_duplicates", "duplicate", WithIndexes(index))
+	cases := []struct {
+		message string
+		index   string
+		fields  []string
+	}{
+		// 声明的索引使用索引中的字段
+		{"E11000 duplicate key error collection: db.test_duplicates index: uniq_phone dup key: { phone: \"1\" }", "uniq_phone", []string{"phone"}},
+		// 未声明的索引从默认的索引名称中解析字段
+		{"E11000 duplicate key error collection: db.test_duplicates index: merchant_id_1_user_info.email_-1_deleted_at_1 dup key: { }", "merchant_id_1_user_info.email_-1_deleted_at_1", []string{"user_info.email"}},
+		{"E11000 duplicate key error collection: db.test_duplicates index: _id_ dup key: { }", "_id_", []string{"_id"}},
+		{"E11000 duplicate key error", "", []string{}},
+	}
+	for _, c := range cases {
+		err := repo.duplicateError(duplicateKeyError(c.message))
+		dup := &cerrors.DuplicateError{}
+		if !errors.As(err, &dup) {
+			t.Fatalf("%s: expected duplicate error, got %v", c.message, err)
+		}
+		if dup.Index != c.index || len(dup.Fields) != len(c.fields) {
+			t.Fatalf("%s: expected %s %v, got %s %v", c.message, c.index, c.fields, dup.Index, dup.Fields)
+		}
+		for i := range c.fields {
+			if dup.Fields[i] != c.fields[i] {
+				t.Fatalf("%s: expected %v, got %v", c.message, c.fields, dup.Fields)
+			}
+		}
+	}
+
+	other := errors.New("other")
+	if err := repo.duplicateError(other); err != other {
+		t.Fatalf("other errors should be returned as is, got %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package collections_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/r2day/collections"
+	cerrors "github.com/r2day/collections/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDuplicateKey(t *testing.T) {
+	repo := newRepo(collections.WithIndexes(collections.UniqueIndex("merchant_id", "name")))
+	ctx := context.Background()
+	if _, err := repo.EnsureIndexes(ctx); err != nil {
+		t.Fatal(err)
+	}
+	id := create(t, repo, merchantA, &item{Name: "a"})
+	create(t, repo, merchantB, &item{Name: "a"})
+	create(t, repo, merchantA, &item{Name: "b"})
+
+	_, err := repo.Create(as(merchantA), &item{Name: "a"})
+	dup := &cerrors.DuplicateError{}
+	if !errors.As(err, &dup) || !errors.Is(err, cerrors.ErrConflict) {
+		t.Fatalf("expected duplicate error, got %v", err)
+	}
+	if !equal(dup.Fields, []string{"name"}) {
+		t.Fatalf("unexpected duplicate fields %v", dup.Fields)
+	}
+	err = repo.Patch(as(merchantA), merchantA, id, collections.Patch{Set: bson.M{"name": "b"}})
+	if !errors.As(err, &dup) {
+		t.Fatalf("expected duplicate error on patch, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+// Package errors 定义数据操作返回的错误
+// 所有模型均返回以下错误（或者对其进行包装），调用方可以通过 errors.Is 进行判断
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrNotFound 记录不存在
+	// 记录属于其他商户或者超出访问级别时同样返回该错误
+	ErrNotFound = errors.New("collections: document not found")
+	// ErrInvalidID 非法的id
+	ErrInvalidID = errors.New("collections: invalid id")
+	// ErrConflict 数据冲突
+	ErrConflict = errors.New("collections: conflict")
+	// ErrForbiddenTenant 缺少或者不允许的商户命名空间
+	ErrForbiddenTenant = errors.New("collections: forbidden tenant")
+	// ErrValidation 数据校验失败
+	ErrValidation = errors.New("collections: validation failed")
+	// ErrForbiddenLevel 访问级别超过了操作者的级别
+	ErrForbiddenLevel = errors.New("collections: forbidden access level")
+	// ErrNoDatabase 没有设置数据库
+	ErrNoDatabase = errors.New("collections: database is not configured")
+	// ErrDisabled 记录已经停用或者删除，例如停用的账号
+	ErrDisabled = errors.New("collections: disabled")
+)
+
+// NotFound 返回包含id信息的 ErrNotFound
+func NotFound(id string) error {
+	return fmt.Errorf("%w: %s", ErrNotFound, id)
+}
+
+// InvalidID 返回包含id信息的 ErrInvalidID
+func InvalidID(id string) error {
+	return fmt.Errorf("%w: %q", ErrInvalidID, id)
+}
+
+// StatusCode 返回错误对应的http状态码
+// 未定义的错误返回 500
+func StatusCode(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidID), errors.Is(err, ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, ErrForbiddenTenant), errors.Is(err, ErrForbiddenLevel), errors.Is(err, ErrDisabled):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// FieldError 字段校验失败的原因
+type FieldError struct {
+	// 字段，例如 user_info.phone
+	Field string `json:"field"`
+	// 校验规则，例如 required、range、phone
+	Rule string `json:"rule"`
+	// 错误描述
+	Message string `json:"message"`
+}
+
+// ValidationError 数据校验失败
+// 包含所有校验失败的字段，errors.Is(err, ErrValidation) 返回 true
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+// Error 返回错误描述
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Field+": "+f.Message)
+	}
+	return fmt.Sprintf("%s: %s", ErrValidation, strings.Join(msgs, "; "))
+}
+
+// Unwrap 返回 ErrValidation
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+// DuplicateError 违反唯一约束
+// 例如卡号已经存在，errors.Is(err, ErrConflict) 返回 true
+type DuplicateError struct {
+	// 唯一索引的名称
+	Index string `json:"index"`
+	// 重复的字段，不包括 merchant_id 等命名空间字段
+	Fields []string `json:"fields"`
+}
+
+// Error 返回错误描述
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("%s: %s already exists", ErrConflict, strings.Join(e.Fields, ", "))
+}
+
+// Unwrap 返回 ErrConflict
+func (e *DuplicateError) Unwrap() error {
+	return ErrConflict
+}
@@ -0,0 +1,34 @@
+package collections
+
+import (
+	"github.com/r2day/rest"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// FilterByFrom 按来源过滤
+func FilterByFrom(urlParams *rest.UrlParams) bson.D {
+	return filterByValue("from", urlParams.FilterCommon.From)
+}
+
+// FilterByGender 按性别过滤
+func FilterByGender(urlParams *rest.UrlParams) bson.D {
+	return filterByValue("gender", urlParams.FilterCommon.Gender)
+}
+
+// FilterByCategoryName 按分类名称过滤
+func FilterByCategoryName(urlParams *rest.UrlParams) bson.D {
+	return filterByValue("category_name", urlParams.FilterCommon.CategoryName)
+}
+
+// FilterByOrderCategory 按订单分类过滤
+func FilterByOrderCategory(urlParams *rest.UrlParams) bson.D {
+	return filterByValue("order_category", urlParams.FilterCommon.OrderCategory)
+}
+
+// filterByValue 值不为空时才添加过滤器
+func filterByValue(key string, val string) bson.D {
+	if val == "" {
+		return nil
+	}
+	return bson.D{{Key: key, Value: val}}
+}
@@ -0,0 +1,114 @@
+package collections_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/r2day/collections"
+	"github.com/r2day/collections/memory"
+	"github.com/r2day/rest"
+)
+
+// item 测试使用的模型
+type item struct {
+	collections.BaseModel `bson:",inline"`
+
+	Name  string            `json:"name" bson:"name"`
+	Qty   int64             `json:"qty" bson:"qty"`
+	Tags  []string          `json:"tags" bson:"tags"`
+	Price collections.Money `json:"price" bson:"price"`
+	Note  string            `json:"note,omitempty" bson:"note,omitempty"`
+}
+
+// 测试使用的商户以及访问范围
+var (
+	merchantA = collections.Scope{MerchantID: "m-a", AccessLevel: collections.LevelAdmin, AccountID: "acc-a"}
+	merchantB = collections.Scope{MerchantID: "m-b", AccessLevel: collections.LevelAdmin, AccountID: "acc-b"}
+)
+
+// newRepo 返回使用独立内存存储的数据仓库
+func newRepo(opts ...collections.Option) *collections.Repository[item, *item] {
+	opts = append([]collections.Option{
+		collections.WithSortableFields("name", "qty"),
+		collections.WithFilterFields(
+			collections.StringFilter("name"),
+			collections.IntFilter("qty"),
+			collections.StringFilter("tags"),
+			collections.MoneyFilter("price"),
+		),
+	}, opts...)
+	return collections.NewRepository[item]("test_items", "item", opts...).UsingStore(memory.NewStore())
+}
+
+// as 返回包含操作者访问范围的 context
+func as(scope collections.Scope) context.Context {
+	return collections.WithScope(context.Background(), scope)
+}
+
+// create 以指定的访问范围创建记录
+func create(t *testing.T, repo *collections.Repository[item, *item], scope collections.Scope, m *item) string {
+	t.Helper()
+	id, err := repo.Create(as(scope), m)
+	if err != nil {
+		t.Fatalf("create %s: %v", m.Name, err)
+	}
+	return id
+}
+
+// params 返回列表查询参数
+func params(filter map[string][]string) *rest.UrlParams {
+	return &rest.UrlParams{Range: rest.ReqRange{Limit: 100}, FilterMap: filter}
+}
+
+// names 返回记录的名称
+func names(items []*item) []string {
+	result := make([]string, 0, len(items))
+	for _, m := range items {
+		result = append(result, m.Name)
+	}
+	return result
+}
+
+// equal 判断两个列表是否相同
+func equal(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// expectError 检查错误类型
+func expectError(t *testing.T, err error, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Fatalf("expected %v, got %v", target, err)
+	}
+}
+
+// useStore 在测试期间使用指定的默认存储后端
+func useStore(t *testing.T, store collections.Store) {
+	t.Helper()
+	collections.SetDefaultStore(store)
+	t.Cleanup(func() { collections.SetDefaultStore(nil) })
+}
+
+// saveManager 保存旧的管理员账号并返回id
+func saveManager(t *testing.T, merchantID string, phone string, name string) string {
+	t.Helper()
+	ctx := as(collections.Scope{MerchantID: merchantID, AccessLevel: collections.LevelAdmin})
+	m := &collections.ManagerAccountModel{Phone: phone, Name: name}
+	if err := m.SimpleSave(ctx); err != nil {
+		t.Fatal(err)
+	}
+	found := &collections.ManagerAccountModel{Phone: phone}
+	if err := found.FindByPhone(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return found.ID.Hex()
+}
@@ -0,0 +1,30 @@
+package collections
+
+import (
+	cerrors "github.com/r2day/collections/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ObjectIDFromHex 将字符串转换为 ObjectID
+// 非法的id返回 ErrInvalidID
+func ObjectIDFromHex(id string) (primitive.ObjectID, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, cerrors.InvalidID(id)
+	}
+	return objID, nil
+}
+
+// ObjectIDsFromHex 批量将字符串转换为 ObjectID
+// 任意一个id非法则返回 ErrInvalidID
+func ObjectIDsFromHex(ids []string) ([]primitive.ObjectID, error) {
+	objIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		objID, err := ObjectIDFromHex(id)
+		if err != nil {
+			return nil, err
+		}
+		objIDs = append(objIDs, objID)
+	}
+	return objIDs, nil
+}
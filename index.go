@@ -0,0 +1,226 @@
+package collections
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Index 索引
+type Index struct {
+	// 索引名称，为空时按照 mongo 的规则生成，例如 merchant_id_1_phone_1
+	Name string
+	// 索引字段，1 为升序，-1 为降序
+	Keys bson.D
+	// 是否唯一
+	Unique bool
+}
+
+// NewIndex 创建索引
+// 字段以 - 开头时为降序，例如 NewIndex("merchant_id", "-order_time")
+func NewIndex(keys ...string) Index {
+	index := Index{Keys: make(bson.D, 0, len(keys))}
+	for _, key := range keys {
+		if strings.HasPrefix(key, "-") {
+			index.Keys = append(index.Keys, bson.E{Key: key[1:], Value: -1})
+			continue
+		}
+		index.Keys = append(index.Keys, bson.E{Key: key, Value: 1})
+	}
+	return index
+}
+
+// UniqueIndex 创建唯一索引
+// 开启软删除的模型会自动加上 deleted_at，已删除的记录不影响唯一性
+func UniqueIndex(keys ...string) Index {
+	index := NewIndex(keys...)
+	index.Unique = true
+	return index
+}
+
+// indexName 返回 mongo 默认的索引名称
+func indexName(keys bson.D) string {
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s_%d", k.Key, indexDirection(k.Value)))
+	}
+	return strings.Join(parts, "_")
+}
+
+// indexDirection 返回索引字段的方向
+func indexDirection(v interface{}) int {
+	switch n := v.(type) {
+	case int:
+		return sign(int64(n))
+	case int32:
+		return sign(int64(n))
+	case int64:
+		return sign(n)
+	case float64:
+		return sign(int64(n))
+	}
+	return 1
+}
+
+// sign 返回整数的符号
+func sign(n int64) int {
+	if n < 0 {
+		return -1
+	}
+	return 1
+}
+
+// sameKeys 判断两个索引的字段以及方向是否相同
+func (i Index) sameKeys(o Index) bool {
+	if len(i.Keys) != len(o.Keys) {
+		return false
+	}
+	for n := range i.Keys {
+		if i.Keys[n].Key != o.Keys[n].Key || indexDirection(i.Keys[n].Value) != indexDirection(o.Keys[n].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// WithIndexes 声明数据表的索引
+// 通过 EnsureIndexes 在启动时创建
+func WithIndexes(indexes ...Index) Option {
+	return func(c *Config) {
+		c.Indexes = append(c.Indexes, indexes...)
+	}
+}
+
+// defaultIndexes 所有数据表默认的索引
+// 列表查询总是按照商户以及访问级别过滤
+var defaultIndexes = []Index{
+	NewIndex("merchant_id", "access_level"),
+	NewIndex("merchant_id", "-created_at"),
+}
+
+// Indexes 返回数据表的索引，包括默认的索引
+func (r *Repository[T, PT]) Indexes() []Index {
+	declared := append(append([]Index{}, defaultIndexes...), r.conf.Indexes...)
+	if r.conf.SoftDelete {
+		// Purge 按照删除时间查找
+		declared = append(declared, NewIndex(deletedAtField))
+	}
+	indexes := make([]Index, 0, len(declared))
+	for _, index := range declared {
+		index.Keys = append(bson.D{}, index.Keys...)
+		if index.Unique && r.conf.SoftDelete {
+			index.Keys = append(index.Keys, bson.E{Key: deletedAtField, Value: 1})
+		}
+		if index.Name == "" {
+			index.Name = indexName(index.Keys)
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes
+}
+
+// IndexResult 索引同步结果
+type IndexResult struct {
+	// 新建的索引
+	Created []string
+	// 定义发生变化而被删除重建的索引
+	Dropped []string
+	// 已经存在的索引
+	Unchanged []string
+	// 数据库中存在但是没有声明的索引，不会被删除
+	Extra []string
+}
+
+// EnsureIndexes 创建声明的索引
+// 已经存在的索引保持不变，同名但是定义不同的索引会被重建，可以重复执行
+// 已有记录违反唯一索引时返回重复键错误
+func (r *Repository[T, PT]) EnsureIndexes(ctx context.Context) (*IndexResult, error) {
+	logCtx := log.WithField("collection", r.conf.CollectionName)
+	coll := r.collection()
+	existing, err := coll.Indexes(ctx)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+
+	result := &IndexResult{Created: []string{}, Dropped: []string{}, Unchanged: []string{}, Extra: []string{}}
+	matched := make(map[string]bool)
+	for _, index := range r.Indexes() {
+		create := true
+		for _, e := range existing {
+			if e.Name != index.Name && !e.sameKeys(index) {
+				continue
+			}
+			matched[e.Name] = true
+			if e.sameKeys(index) && e.Unique == index.Unique {
+				result.Unchanged = append(result.Unchanged, e.Name)
+				create = false
+				break
+			}
+			if err := coll.DropIndex(ctx, e.Name); err != nil {
+				logCtx.Error(err)
+				return result, err
+			}
+			result.Dropped = append(result.Dropped, e.Name)
+		}
+		if !create {
+			continue
+		}
+		if err := coll.CreateIndex(ctx, index); err != nil {
+			logCtx.WithField("index", index.Name).Error(err)
+			return result, err
+		}
+		result.Created = append(result.Created, index.Name)
+	}
+	for _, e := range existing {
+		if !matched[e.Name] {
+			result.Extra = append(result.Extra, e.Name)
+		}
+	}
+	return result, nil
+}
+
+// indexer 可以创建索引的数据仓库
+type indexer interface {
+	CollectionName() string
+	EnsureIndexes(ctx context.Context) (*IndexResult, error)
+}
+
+var (
+	// registryMu 保护 registry
+	registryMu sync.Mutex
+	// registry 所有通过 NewRepository 创建的数据仓库
+	registry []indexer
+)
+
+// register 记录数据仓库，用于启动时统一创建索引
+func register(r indexer) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, r)
+}
+
+// EnsureIndexes 为所有模型创建声明的索引
+// 一般在启动时调用，使用各模型的默认存储后端；单个数据表失败时继续处理其他数据表
+func EnsureIndexes(ctx context.Context) (map[string]*IndexResult, error) {
+	registryMu.Lock()
+	repos := append([]indexer{}, registry...)
+	registryMu.Unlock()
+
+	results := make(map[string]*IndexResult, len(repos))
+	errs := make([]error, 0)
+	for _, r := range repos {
+		result, err := r.EnsureIndexes(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.CollectionName(), err))
+			continue
+		}
+		results[r.CollectionName()] = result
+	}
+	return results, errors.Join(errs...)
+}
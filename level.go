@@ -0,0 +1,183 @@
+package collections
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	cerrors "github.com/r2day/collections/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// accessLevelField 访问级别字段
+const accessLevelField = "access_level"
+
+// AccessLevel 访问级别
+// 级别越高可以访问的数据越多：账号只能访问小于等于自己级别的记录
+// 记录的级别为创建者的级别，账号的级别为其所有角色中最高的级别
+// 预定义的级别之间留有间隔，可以根据业务插入自定义的级别，例如 LevelStaff + 10
+type AccessLevel uint
+
+const (
+	// LevelPublic 公开，所有账号都可以访问
+	LevelPublic AccessLevel = 0
+	// LevelStaff 员工，例如收银员、服务员
+	LevelStaff AccessLevel = 100
+	// LevelManager 主管，例如门店店长
+	LevelManager AccessLevel = 200
+	// LevelMerchant 商户管理者，例如品牌负责人
+	LevelMerchant AccessLevel = 300
+	// LevelAdmin 管理员，可以访问商户下的所有数据
+	LevelAdmin AccessLevel = 1000
+)
+
+// levelNames 预定义级别的名称
+var levelNames = map[AccessLevel]string{
+	LevelPublic:   "public",
+	LevelStaff:    "staff",
+	LevelManager:  "manager",
+	LevelMerchant: "merchant",
+	LevelAdmin:    "admin",
+}
+
+// String 返回级别的名称，自定义的级别返回数字
+func (l AccessLevel) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return strconv.FormatUint(uint64(l), 10)
+}
+
+// WithLevelFields 声明值为访问级别的字段，例如角色的级别
+// 创建以及更新时不能超过操作者的级别
+func WithLevelFields(fields ...string) Option {
+	return func(c *Config) {
+		c.LevelFields = append(c.LevelFields, fields...)
+	}
+}
+
+// scopeKey 访问范围在 context 中的键
+type scopeKey struct{}
+
+// WithScope 返回包含操作者访问范围的 context
+// 一般在认证之后设置，数据仓库中需要访问范围的方法都以操作者为准，没有时拒绝访问
+// 传入的 Scope 只能是操作者所在的商户，访问级别以及账号id总是使用操作者的
+// Create 时记录的商户号以及访问级别与操作者一致，创建者（created_by）为操作者的账号id
+func WithScope(ctx context.Context, scope Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope)
+}
+
+// ScopeFrom 返回 context 中操作者的访问范围
+func ScopeFrom(ctx context.Context) (Scope, bool) {
+	scope, ok := ctx.Value(scopeKey{}).(Scope)
+	return scope, ok
+}
+
+// actorOf 返回 context 中操作者的访问范围
+// 没有操作者的访问范围时返回 errors.ErrForbiddenLevel，未指定商户号时返回 errors.ErrForbiddenTenant
+func actorOf(ctx context.Context) (Scope, error) {
+	actor, ok := ScopeFrom(ctx)
+	if !ok {
+		return actor, fmt.Errorf("%w: no scope in context", cerrors.ErrForbiddenLevel)
+	}
+	if err := actor.Validate(); err != nil {
+		return actor, err
+	}
+	return actor, nil
+}
+
+// actorScope 返回以 context 中操作者为准的访问范围
+// 传入的访问范围只用于确认商户号，访问级别以及账号id以操作者为准
+// 没有操作者的访问范围时返回 errors.ErrForbiddenLevel，商户号不一致时返回 errors.ErrForbiddenTenant
+func actorScope(ctx context.Context, scope Scope) (Scope, error) {
+	actor, err := actorOf(ctx)
+	if err != nil {
+		return scope, err
+	}
+	if err := scope.Validate(); err != nil {
+		return scope, err
+	}
+	if actor.MerchantID != scope.MerchantID {
+		return scope, fmt.Errorf("%w: %s", cerrors.ErrForbiddenTenant, scope.MerchantID)
+	}
+	return actor, nil
+}
+
+// checkLevel 检查访问级别是否超过操作者的级别
+func checkLevel(scope Scope, field string, level AccessLevel) error {
+	if level > scope.AccessLevel {
+		return fmt.Errorf("%w: %s %s exceeds %s", cerrors.ErrForbiddenLevel, field, level, scope.AccessLevel)
+	}
+	return nil
+}
+
+// levelOf 返回字段中的访问级别
+func levelOf(doc bson.Raw, field string) (AccessLevel, bool, error) {
+	rv, err := doc.LookupErr(strings.Split(field, ".")...)
+	if err != nil || isEmpty(rv) {
+		return 0, false, nil
+	}
+	n, ok := numberOf(rv)
+	if !ok || !n.IsInt() || n.Sign() < 0 || !n.Num().IsUint64() {
+		return 0, false, fmt.Errorf("%w: %s is not an access level", cerrors.ErrValidation, field)
+	}
+	return AccessLevel(n.Num().Uint64()), true, nil
+}
+
+// levelFields 返回值为访问级别的字段
+func (r *Repository[T, PT]) levelFields() []string {
+	return append([]string{accessLevelField}, r.conf.LevelFields...)
+}
+
+// checkLevels 检查记录中的访问级别是否超过操作者的级别
+func (r *Repository[T, PT]) checkLevels(scope Scope, m PT) error {
+	data, err := bson.Marshal(m)
+	if err != nil {
+		return err
+	}
+	for _, field := range r.levelFields() {
+		level, ok, err := levelOf(data, field)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if err := checkLevel(scope, field, level); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkPatchLevels 检查局部更新中的访问级别是否超过操作者的级别
+// 访问级别只能通过 Set 修改
+func (r *Repository[T, PT]) checkPatchLevels(scope Scope, patch Patch) error {
+	set := bson.M{}
+	for key, val := range patch.Set {
+		setPath(set, strings.Split(key, "."), val)
+	}
+	data, err := bson.Marshal(set)
+	if err != nil {
+		return err
+	}
+	for _, field := range r.levelFields() {
+		for _, ops := range []bson.M{patch.Inc, patch.Push, patch.Pull} {
+			for key := range ops {
+				if covers(key, field) || covers(field, key) {
+					return fmt.Errorf("%w: %s can only be set", cerrors.ErrForbiddenLevel, field)
+				}
+			}
+		}
+		level, ok, err := levelOf(data, field)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if err := checkLevel(scope, field, level); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
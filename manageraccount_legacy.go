@@ -0,0 +1,328 @@
+package collections
+
+import (
+	"context"
+
+	cerrors "github.com/r2day/collections/errors"
+	"github.com/r2day/rest"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// managerAccountFilterFields 管理员账号可以过滤的字段
+var managerAccountFilterFields = []FilterField{
+	StringFilter("name"),
+	StringFilter("phone"),
+	StringFilter("email"),
+	BoolFilter("is_admin"),
+	StringFilter("roles"),
+}
+
+// managerAccountSortableFields 管理员账号可以排序的字段
+var managerAccountSortableFields = []string{"name", "phone", "email"}
+
+// legacyManagerAccounts 旧表 sys_manage_account 中的管理员账号
+// 默认的管理员账号存储，访问范围的规则与数据仓库相同
+type legacyManagerAccounts struct{}
+
+// collection 返回默认存储后端中的旧表
+func (legacyManagerAccounts) collection() Collection {
+	return DefaultStore().Collection(ManagerAccountCollection)
+}
+
+// managerAccountScope 返回访问范围的过滤条件
+// 旧表中的记录没有访问级别，视为 LevelPublic
+func managerAccountScope(scope Scope) bson.D {
+	return bson.D{
+		{Key: "merchant_id", Value: scope.MerchantID},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: accessLevelField, Value: bson.D{{Key: "$lte", Value: scope.AccessLevel}}}},
+			bson.D{{Key: accessLevelField, Value: bson.D{{Key: "$exists", Value: false}}}},
+		}},
+	}
+}
+
+// managerAccountFilter 返回访问范围内指定id的过滤条件
+func managerAccountFilter(scope Scope, id string) (bson.D, error) {
+	objID, err := ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	return append(bson.D{{Key: idField, Value: objID}}, managerAccountScope(scope)...), nil
+}
+
+// Create 创建账号
+// 商户号与 context 中的操作者一致
+func (s legacyManagerAccounts) Create(ctx context.Context, m *ManagerAccountModel) error {
+	scope, err := actorOf(ctx)
+	if err != nil {
+		return err
+	}
+	m.MerchantId = scope.MerchantID
+	// 保存时间设定
+	m.CreatedAt = now()
+	m.UpdatedAt = m.CreatedAt
+	if m.Password != "" {
+		hash, err := HashPassword(m.Password)
+		if err != nil {
+			return err
+		}
+		m.Password = hash
+	}
+
+	// 插入记录
+	_, err = s.collection().InsertOne(ctx, m)
+	return err
+}
+
+// UpdateByID 通过 m.ID 整体更新操作者访问范围内的账号
+func (s legacyManagerAccounts) UpdateByID(ctx context.Context, m *ManagerAccountModel) error {
+	scope, err := actorOf(ctx)
+	if err != nil {
+		return err
+	}
+	return s.Update(ctx, scope, m.ID.Hex(), m)
+}
+
+// Update 整体更新访问范围内的账号
+// 记录不能被移动到其他商户下
+func (s legacyManagerAccounts) Update(ctx context.Context, scope Scope, id string, m *ManagerAccountModel) error {
+	scope, err := actorScope(ctx, scope)
+	if err != nil {
+		return err
+	}
+	filter, err := managerAccountFilter(scope, id)
+	if err != nil {
+		return err
+	}
+	m.MerchantId = scope.MerchantID
+	m.UpdatedAt = now()
+	coll := s.collection()
+	set, err := m.setDocument(ctx, coll, filter)
+	if err != nil {
+		return err
+	}
+	result, err := coll.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: set}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount < 1 {
+		return cerrors.NotFound(id)
+	}
+	return nil
+}
+
+// Delete 删除访问范围内的账号
+func (s legacyManagerAccounts) Delete(ctx context.Context, scope Scope, id string) error {
+	scope, err := actorScope(ctx, scope)
+	if err != nil {
+		return err
+	}
+	filter, err := managerAccountFilter(scope, id)
+	if err != nil {
+		return err
+	}
+	result, err := s.collection().DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount < 1 {
+		return cerrors.NotFound(id)
+	}
+	return nil
+}
+
+// FindOne 通过过滤条件查找一个账号
+func (s legacyManagerAccounts) FindOne(ctx context.Context, filter bson.D) (*ManagerAccountModel, error) {
+	raw, err := s.collection().FindOne(ctx, filter)
+	if err == mongo.ErrNoDocuments {
+		return nil, cerrors.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := &ManagerAccountModel{}
+	if err := bson.Unmarshal(raw, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetOne 获取访问范围内的账号
+func (s legacyManagerAccounts) GetOne(ctx context.Context, scope Scope, id string) (*ManagerAccountModel, error) {
+	scope, err := actorScope(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := managerAccountFilter(scope, id)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.FindOne(ctx, filter)
+	if err == cerrors.ErrNotFound {
+		return nil, cerrors.NotFound(id)
+	}
+	return result, err
+}
+
+// GetMany 获取访问范围内指定id的账号
+func (s legacyManagerAccounts) GetMany(ctx context.Context, scope Scope, ids []string) ([]*ManagerAccountModel, error) {
+	scope, err := actorScope(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	objIDs, err := ObjectIDsFromHex(ids)
+	if err != nil {
+		return nil, err
+	}
+	filter := append(bson.D{{Key: idField, Value: bson.D{{Key: "$in", Value: objIDs}}}}, managerAccountScope(scope)...)
+	raws, err := s.collection().Find(ctx, filter, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeManagerAccounts(raws)
+}
+
+// GetList 获取访问范围内的账号列表
+// 排序规则与 Repository.GetList 相同，只允许对声明的字段排序，通过id查询的引用同样只返回访问范围内的记录
+func (s legacyManagerAccounts) GetList(ctx context.Context, scope Scope, urlParams *rest.UrlParams) ([]*ManagerAccountModel, int64, error) {
+	// 声明日志基本信息
+	logCtx := log.WithField("merchantID", scope.MerchantID).WithField("urlParams.FilterMap", urlParams.FilterMap)
+	scope, err := actorScope(ctx, scope)
+	if err != nil {
+		return nil, 0, err
+	}
+	// 以商户id为基本命名空间，并且只能看到小于等于自己的级别的数据
+	filters := managerAccountScope(scope)
+
+	// 判断是否是通过id查询
+	// 一般对应于 ReferenceArrayInput 和 ReferenceManyField
+	refIDs := make([]string, 0)
+	isReference := false
+	filterMap := make(map[string][]string, len(urlParams.FilterMap))
+	for key, val := range urlParams.FilterMap {
+		if key == "account" || key == "id" {
+			refIDs = append(refIDs, val...)
+			isReference = true
+			continue
+		}
+		filterMap[key] = val
+	}
+	if isReference {
+		objIDs, err := ObjectIDsFromHex(refIDs)
+		if err != nil {
+			return nil, 0, err
+		}
+		filters = append(filters, bson.E{Key: idField, Value: bson.D{{Key: "$in", Value: objIDs}}})
+	}
+
+	// 添加更多过滤器
+	// 只允许过滤声明的字段
+	conds, err := parseFilters(filterMap, managerAccountFilterFields, MerchantLocation(scope.MerchantID))
+	if err != nil {
+		return nil, 0, err
+	}
+	filters = append(filters, conds...)
+
+	// 添加状态过滤器
+	if urlParams.HasFilter && !isReference && !hasFilterOn(filterMap, "status") {
+		filters = append(filters, bson.E{Key: "status", Value: urlParams.FilterCommon.Status})
+	}
+
+	// 排序方式
+	sort, err := resolveSort(SortFromParams(urlParams), managerAccountSortableFields, managerAccountFilterFields)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	logCtx.WithField("filters", filters).Info("final filters has been combine")
+	coll := s.collection()
+	// 获取总数（含过滤规则）
+	total, err := coll.CountDocuments(ctx, filters)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// 进行必要分页处理
+	opt := &FindOptions{
+		Sort:  sort.BSON(),
+		Skip:  int64(urlParams.Range.Offset),
+		Limit: int64(urlParams.Range.Limit),
+	}
+	raws, err := coll.Find(ctx, filters, opt)
+	if err != nil {
+		return nil, total, err
+	}
+	results, err := decodeManagerAccounts(raws)
+	if err != nil {
+		return nil, total, err
+	}
+	return results, total, nil
+}
+
+// SetPassword 保存已经计算好的密码哈希值
+func (s legacyManagerAccounts) SetPassword(ctx context.Context, id primitive.ObjectID, hash string) error {
+	filter := bson.D{{Key: idField, Value: id}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "password", Value: hash}}}}
+	result, err := s.collection().UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount < 1 {
+		return cerrors.NotFound(id.Hex())
+	}
+	return nil
+}
+
+// decodeManagerAccounts 将查询结果解析为管理员账号
+func decodeManagerAccounts(raws []bson.Raw) ([]*ManagerAccountModel, error) {
+	results := make([]*ManagerAccountModel, 0, len(raws))
+	for _, raw := range raws {
+		m := &ManagerAccountModel{}
+		if err := bson.Unmarshal(raw, m); err != nil {
+			return nil, err
+		}
+		results = append(results, m)
+	}
+	return results, nil
+}
+
+// setDocument 返回更新时的 $set 内容
+// 不修改创建时间等不可变字段；密码为空或者与保存的值相同时保持不变，否则作为明文计算哈希值
+func (m *ManagerAccountModel) setDocument(ctx context.Context, coll Collection, filter bson.D) (bson.D, error) {
+	set, err := setDocument(m)
+	if err != nil {
+		return nil, err
+	}
+	set = omitEmpty(set, []string{"password"})
+	if m.Password == "" {
+		return set, nil
+	}
+	stored := &ManagerAccountModel{}
+	raw, err := coll.FindOne(ctx, filter)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	if err == nil {
+		if err := bson.Unmarshal(raw, stored); err != nil {
+			return nil, err
+		}
+	}
+	kept := make(bson.D, 0, len(set))
+	for _, e := range set {
+		if e.Key == "password" {
+			if stored.Password == m.Password {
+				continue
+			}
+			hash, err := HashPassword(m.Password)
+			if err != nil {
+				return nil, err
+			}
+			e.Value = hash
+		}
+		kept = append(kept, e)
+	}
+	return kept, nil
+}
@@ -0,0 +1,81 @@
+package collections_test
+
+import (
+	"testing"
+
+	"github.com/r2day/collections"
+	cerrors "github.com/r2day/collections/errors"
+	"github.com/r2day/collections/memory"
+	"github.com/r2day/rest"
+)
+
+func TestManagerAccountScope(t *testing.T) {
+	useStore(t, memory.NewStore())
+	id := saveManager(t, merchantA.MerchantID, "13800138000", "a")
+	m := &collections.ManagerAccountModel{}
+
+	_, err := m.Detail(as(merchantB), merchantB, id)
+	expectError(t, err, cerrors.ErrNotFound)
+	err = (&collections.ManagerAccountModel{Phone: "13800138000", Name: "x", AccountId: id}).Update(as(merchantB), merchantB, id)
+	expectError(t, err, cerrors.ErrNotFound)
+	err = m.Delete(as(merchantB), merchantB, id)
+	expectError(t, err, cerrors.ErrNotFound)
+
+	if err := (&collections.ManagerAccountModel{Phone: "13800138000", Name: "b", AccountId: id}).Update(as(merchantA), merchantA, id); err != nil {
+		t.Fatal(err)
+	}
+	found, err := m.Detail(as(merchantA), merchantA, id)
+	if err != nil || found.Name != "b" || found.MerchantId != merchantA.MerchantID {
+		t.Fatalf("unexpected account %+v %v", found, err)
+	}
+	if err := m.Delete(as(merchantA), merchantA, id); err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Detail(as(merchantA), merchantA, id)
+	expectError(t, err, cerrors.ErrNotFound)
+}
+
+func TestManagerAccountList(t *testing.T) {
+	useStore(t, memory.NewStore())
+	saveManager(t, merchantA.MerchantID, "13800138001", "c")
+	saveManager(t, merchantA.MerchantID, "13800138002", "a")
+	saveManager(t, merchantA.MerchantID, "13800138003", "b")
+	saveManager(t, merchantB.MerchantID, "13800138004", "d")
+	list := func(key string, sortType rest.SortTypeEnum) ([]string, error) {
+		urlParams := params(nil)
+		urlParams.Sort = rest.ReqSort{Key: key, SortType: sortType}
+		results, _, err := (&collections.ManagerAccountModel{}).List(as(merchantA), merchantA, urlParams)
+		found := make([]string, 0, len(results))
+		for _, m := range results {
+			found = append(found, m.Name)
+		}
+		return found, err
+	}
+
+	if found, err := list("name", rest.AES); err != nil || !equal(found, []string{"a", "b", "c"}) {
+		t.Fatalf("expected ascending names, got %v %v", found, err)
+	}
+	if found, err := list("name", rest.DESC); err != nil || !equal(found, []string{"c", "b", "a"}) {
+		t.Fatalf("expected descending names, got %v %v", found, err)
+	}
+	_, err := list("password", rest.AES)
+	expectError(t, err, cerrors.ErrValidation)
+}
+
+func TestManagerAccountReference(t *testing.T) {
+	useStore(t, memory.NewStore())
+	idA := saveManager(t, merchantA.MerchantID, "13800138001", "a")
+	idB := saveManager(t, merchantB.MerchantID, "13800138002", "b")
+	m := &collections.ManagerAccountModel{}
+
+	found, err := m.GetManyInIds(as(merchantA), merchantA, []string{idA, idB})
+	if err != nil || len(found) != 1 || found[0].Name != "a" {
+		t.Fatalf("only accounts in scope should be returned, got %v %v", found, err)
+	}
+	list, total, err := m.List(as(merchantA), merchantA, params(map[string][]string{"id": {idA, idB}}))
+	if err != nil || total != 1 || len(list) != 1 || list[0].Name != "a" {
+		t.Fatalf("references should be scoped, got %v %d %v", list, total, err)
+	}
+	_, err = m.GetManyInIds(as(collections.Scope{}), collections.Scope{}, []string{idA})
+	expectError(t, err, cerrors.ErrForbiddenTenant)
+}
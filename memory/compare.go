@@ -0,0 +1,192 @@
+package memory
+
+import (
+	"bytes"
+	"math"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// typeOrder 返回值的类型排序
+// 参考 mongo 的 BSON 类型比较顺序
+func typeOrder(v interface{}) int {
+	switch v.(type) {
+	case nil, primitive.Null, primitive.Undefined:
+		return 1
+	case int32, int64, float64, primitive.Decimal128:
+		return 2
+	case string, primitive.Symbol:
+		return 3
+	case bson.D:
+		return 4
+	case bson.A:
+		return 5
+	case primitive.Binary:
+		return 6
+	case primitive.ObjectID:
+		return 7
+	case bool:
+		return 8
+	case primitive.DateTime:
+		return 9
+	case primitive.Timestamp:
+		return 10
+	case primitive.Regex:
+		return 11
+	default:
+		return 12
+	}
+}
+
+// toFloat 将数字转换为 float64
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case primitive.Decimal128:
+		f, err := strconvDecimal(n)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// compare 比较两个值
+// 不同类型之间按照类型顺序进行比较
+func compare(a, b interface{}) int {
+	ta, tb := typeOrder(a), typeOrder(b)
+	if ta != tb {
+		return sign(ta - tb)
+	}
+
+	switch av := a.(type) {
+	case nil, primitive.Null, primitive.Undefined:
+		return 0
+	case int32, int64, float64, primitive.Decimal128:
+		af, _ := toFloat(av)
+		bf, _ := toFloat(b)
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		// 大整数比较时避免精度损失
+		ai, aok := av.(int64)
+		bi, bok := b.(int64)
+		if aok && bok && ai != bi {
+			if ai < bi {
+				return -1
+			}
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(av, stringOf(b))
+	case primitive.Symbol:
+		return strings.Compare(string(av), stringOf(b))
+	case bson.D:
+		bv := b.(bson.D)
+		for i := 0; i < len(av) && i < len(bv); i++ {
+			if c := strings.Compare(av[i].Key, bv[i].Key); c != 0 {
+				return c
+			}
+			if c := compare(av[i].Value, bv[i].Value); c != 0 {
+				return c
+			}
+		}
+		return sign(len(av) - len(bv))
+	case bson.A:
+		bv := b.(bson.A)
+		for i := 0; i < len(av) && i < len(bv); i++ {
+			if c := compare(av[i], bv[i]); c != 0 {
+				return c
+			}
+		}
+		return sign(len(av) - len(bv))
+	case primitive.Binary:
+		return bytes.Compare(av.Data, b.(primitive.Binary).Data)
+	case primitive.ObjectID:
+		bv := b.(primitive.ObjectID)
+		return bytes.Compare(av[:], bv[:])
+	case bool:
+		bv := b.(bool)
+		switch {
+		case av == bv:
+			return 0
+		case !av:
+			return -1
+		default:
+			return 1
+		}
+	case primitive.DateTime:
+		return sign64(int64(av) - int64(b.(primitive.DateTime)))
+	case primitive.Timestamp:
+		bv := b.(primitive.Timestamp)
+		if av.T != bv.T {
+			return sign64(int64(av.T) - int64(bv.T))
+		}
+		return sign64(int64(av.I) - int64(bv.I))
+	case primitive.Regex:
+		bv := b.(primitive.Regex)
+		return strings.Compare(av.Pattern+"/"+av.Options, bv.Pattern+"/"+bv.Options)
+	}
+	return 0
+}
+
+// equal 判断两个值是否相等
+func equal(a, b interface{}) bool {
+	return typeOrder(a) == typeOrder(b) && compare(a, b) == 0
+}
+
+// sameType 判断两个值是否可以进行大小比较
+// mongo 中 $gt/$lt 等操作仅在相同类型之间生效
+func sameType(a, b interface{}) bool {
+	return typeOrder(a) == typeOrder(b)
+}
+
+// stringOf 返回字符串的值
+func stringOf(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case primitive.Symbol:
+		return string(s)
+	}
+	return ""
+}
+
+// strconvDecimal 将 Decimal128 转换为 float64
+func strconvDecimal(d primitive.Decimal128) (float64, error) {
+	bi, exp, err := d.BigInt()
+	if err != nil {
+		return 0, err
+	}
+	f, _ := bi.Float64()
+	return f * math.Pow10(exp), nil
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+func sign64(n int64) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
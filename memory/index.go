@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/r2day/collections"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Indexes 返回数据表的索引，不包括 _id 索引
+func (c *Collection) Indexes(ctx context.Context) ([]collections.Index, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]collections.Index{}, c.indexes...), nil
+}
+
+// CreateIndex 创建索引
+// 只有唯一索引会生效，已有记录违反唯一索引时返回重复键错误
+func (c *Collection) CreateIndex(ctx context.Context, index collections.Index) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, existing := range c.indexes {
+		if existing.Name == index.Name {
+			return fmt.Errorf("memory: index %s already exists", index.Name)
+		}
+	}
+	if index.Unique {
+		all := make([]int, len(c.docs))
+		for i := range c.docs {
+			all[i] = i
+		}
+		if err := c.checkIndex(index, c.docs, all); err != nil {
+			return err
+		}
+	}
+	c.indexes = append(c.indexes, index)
+	return nil
+}
+
+// DropIndex 删除索引
+func (c *Collection) DropIndex(ctx context.Context, name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, index := range c.indexes {
+		if index.Name == name {
+			c.indexes = append(c.indexes[:i], c.indexes[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("memory: index %s not found", name)
+}
+
+// checkUnique 检查修改后的记录是否违反唯一索引
+// docs 为修改后的全部记录，changed 为被修改的记录下标
+func (c *Collection) checkUnique(docs []bson.D, changed []int) error {
+	for _, index := range c.indexes {
+		if !index.Unique {
+			continue
+		}
+		if err := c.checkIndex(index, docs, changed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkIndex 检查记录是否违反指定的唯一索引
+// 缺失的字段视为 null，与 mongo 的非稀疏索引一致
+func (c *Collection) checkIndex(index collections.Index, docs []bson.D, changed []int) error {
+	for _, i := range changed {
+		key := indexKey(docs[i], index)
+		for j, doc := range docs {
+			if j != i && equal(indexKey(doc, index), key) {
+				return c.duplicateKeyError(index.Name, key)
+			}
+		}
+	}
+	return nil
+}
+
+// indexKey 返回记录在索引中的键
+func indexKey(doc bson.D, index collections.Index) bson.D {
+	key := make(bson.D, 0, len(index.Keys))
+	for _, k := range index.Keys {
+		v, _ := getPath(doc, strings.Split(k.Key, "."))
+		key = append(key, bson.E{Key: k.Key, Value: v})
+	}
+	return key
+}
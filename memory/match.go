@@ -0,0 +1,394 @@
+package memory
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// lookup 根据路径获取字段的值
+// 路径中间遇到数组时会展开数组中的每一个元素
+func lookup(v interface{}, parts []string) ([]interface{}, bool) {
+	switch doc := v.(type) {
+	case bson.D:
+		for _, e := range doc {
+			if e.Key != parts[0] {
+				continue
+			}
+			if len(parts) == 1 {
+				return []interface{}{e.Value}, true
+			}
+			return lookup(e.Value, parts[1:])
+		}
+	case bson.A:
+		// 数组下标
+		if i, err := strconv.Atoi(parts[0]); err == nil {
+			if i < 0 || i >= len(doc) {
+				return nil, false
+			}
+			if len(parts) == 1 {
+				return []interface{}{doc[i]}, true
+			}
+			return lookup(doc[i], parts[1:])
+		}
+		// 展开数组
+		values := make([]interface{}, 0)
+		found := false
+		for _, elem := range doc {
+			if vs, ok := lookup(elem, parts); ok {
+				values = append(values, vs...)
+				found = true
+			}
+		}
+		return values, found
+	}
+	return nil, false
+}
+
+// match 判断记录是否满足过滤条件
+func match(doc bson.D, filter bson.D) (bool, error) {
+	for _, e := range filter {
+		ok, err := matchElement(doc, e)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchElement 判断记录是否满足一个过滤条件
+func matchElement(doc bson.D, e bson.E) (bool, error) {
+	switch e.Key {
+	case "$and", "$or", "$nor":
+		filters, ok := e.Value.(bson.A)
+		if !ok {
+			return false, fmt.Errorf("memory: %s must be an array", e.Key)
+		}
+		for _, f := range filters {
+			sub, ok := f.(bson.D)
+			if !ok {
+				return false, fmt.Errorf("memory: %s entries must be documents", e.Key)
+			}
+			matched, err := match(doc, sub)
+			if err != nil {
+				return false, err
+			}
+			switch {
+			case e.Key == "$and" && !matched:
+				return false, nil
+			case e.Key == "$or" && matched:
+				return true, nil
+			case e.Key == "$nor" && matched:
+				return false, nil
+			}
+		}
+		return e.Key != "$or", nil
+	}
+
+	values, found := lookup(doc, strings.Split(e.Key, "."))
+	return matchCondition(values, found, e.Value)
+}
+
+// isOperatorDoc 判断是否是操作符文档，例如 {"$gte": 1}
+func isOperatorDoc(v interface{}) (bson.D, bool) {
+	d, ok := v.(bson.D)
+	if !ok || len(d) == 0 {
+		return nil, false
+	}
+	return d, strings.HasPrefix(d[0].Key, "$")
+}
+
+// matchCondition 判断字段的值是否满足条件
+func matchCondition(values []interface{}, found bool, cond interface{}) (bool, error) {
+	ops, ok := isOperatorDoc(cond)
+	if !ok {
+		return matchEqual(values, found, cond)
+	}
+
+	for _, op := range ops {
+		matched, err := matchOperator(values, found, op, ops)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// expand 返回字段的值以及数组中的元素
+func expand(values []interface{}) []interface{} {
+	expanded := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		expanded = append(expanded, v)
+		if arr, ok := v.(bson.A); ok {
+			expanded = append(expanded, arr...)
+		}
+	}
+	return expanded
+}
+
+// matchEqual 判断字段的值是否等于条件
+// 字段为数组时，数组整体或者任意元素相等即满足
+func matchEqual(values []interface{}, found bool, cond interface{}) (bool, error) {
+	if re, ok := cond.(primitive.Regex); ok {
+		return matchRegex(values, re.Pattern, re.Options)
+	}
+	if cond == nil {
+		if !found {
+			return true, nil
+		}
+	}
+	for _, v := range expand(values) {
+		if equal(v, cond) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// matchOperator 判断字段的值是否满足操作符
+func matchOperator(values []interface{}, found bool, op bson.E, ops bson.D) (bool, error) {
+	switch op.Key {
+	case "$eq":
+		return matchEqual(values, found, op.Value)
+	case "$ne":
+		matched, err := matchEqual(values, found, op.Value)
+		return !matched, err
+	case "$gt", "$gte", "$lt", "$lte":
+		for _, v := range expand(values) {
+			if !sameType(v, op.Value) {
+				continue
+			}
+			c := compare(v, op.Value)
+			if (op.Key == "$gt" && c > 0) || (op.Key == "$gte" && c >= 0) ||
+				(op.Key == "$lt" && c < 0) || (op.Key == "$lte" && c <= 0) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$in", "$nin":
+		candidates, ok := op.Value.(bson.A)
+		if !ok {
+			return false, fmt.Errorf("memory: %s needs an array", op.Key)
+		}
+		matched := false
+		for _, c := range candidates {
+			ok, err := matchEqual(values, found, c)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				matched = true
+				break
+			}
+		}
+		return matched == (op.Key == "$in"), nil
+	case "$exists":
+		return found == truthy(op.Value), nil
+	case "$regex":
+		options := ""
+		for _, o := range ops {
+			if o.Key == "$options" {
+				options = stringOf(o.Value)
+			}
+		}
+		switch re := op.Value.(type) {
+		case primitive.Regex:
+			if options == "" {
+				options = re.Options
+			}
+			return matchRegex(values, re.Pattern, options)
+		case string:
+			return matchRegex(values, re, options)
+		}
+		return false, fmt.Errorf("memory: $regex needs a string")
+	case "$options":
+		return true, nil
+	case "$type":
+		for _, v := range expand(values) {
+			matched, err := matchType(v, op.Value)
+			if err != nil || matched {
+				return matched, err
+			}
+		}
+		return false, nil
+	case "$not":
+		matched, err := matchCondition(values, found, op.Value)
+		return !matched, err
+	case "$size":
+		n, ok := toFloat(op.Value)
+		if !ok {
+			return false, fmt.Errorf("memory: $size needs a number")
+		}
+		for _, v := range values {
+			if arr, ok := v.(bson.A); ok && float64(len(arr)) == n {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$elemMatch":
+		for _, v := range values {
+			arr, ok := v.(bson.A)
+			if !ok {
+				continue
+			}
+			for _, elem := range arr {
+				matched, err := matchElem(elem, op.Value)
+				if err != nil {
+					return false, err
+				}
+				if matched {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("memory: unsupported operator %s", op.Key)
+}
+
+// matchElem 判断数组元素是否满足 $elemMatch 条件
+func matchElem(elem interface{}, cond interface{}) (bool, error) {
+	if _, ok := isOperatorDoc(cond); ok {
+		return matchCondition([]interface{}{elem}, true, cond)
+	}
+	doc, ok := elem.(bson.D)
+	if !ok {
+		return false, nil
+	}
+	filter, ok := cond.(bson.D)
+	if !ok {
+		return false, fmt.Errorf("memory: $elemMatch needs a document")
+	}
+	return match(doc, filter)
+}
+
+// matchRegex 判断字段的值是否满足正则表达式
+func matchRegex(values []interface{}, pattern string, options string) (bool, error) {
+	flags := ""
+	for _, o := range options {
+		switch o {
+		case 'i', 'm', 's':
+			flags += string(o)
+		}
+	}
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return false, err
+	}
+	for _, v := range expand(values) {
+		switch s := v.(type) {
+		case string:
+			if re.MatchString(s) {
+				return true, nil
+			}
+		case primitive.Symbol:
+			if re.MatchString(string(s)) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// truthy 判断值是否为真
+func truthy(v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case nil:
+		return false
+	}
+	if f, ok := toFloat(v); ok {
+		return f != 0
+	}
+	return true
+}
+
+// bsonTypes $type 支持的类型别名以及对应的类型编号
+var bsonTypes = map[string]bsontype.Type{
+	"double":    bsontype.Double,
+	"string":    bsontype.String,
+	"object":    bsontype.EmbeddedDocument,
+	"array":     bsontype.Array,
+	"objectId":  bsontype.ObjectID,
+	"bool":      bsontype.Boolean,
+	"date":      bsontype.DateTime,
+	"null":      bsontype.Null,
+	"regex":     bsontype.Regex,
+	"int":       bsontype.Int32,
+	"timestamp": bsontype.Timestamp,
+	"long":      bsontype.Int64,
+	"decimal":   bsontype.Decimal128,
+}
+
+// typeOf 返回值的 bson 类型
+func typeOf(v interface{}) bsontype.Type {
+	switch v.(type) {
+	case nil, primitive.Null:
+		return bsontype.Null
+	case float64:
+		return bsontype.Double
+	case string:
+		return bsontype.String
+	case bson.D:
+		return bsontype.EmbeddedDocument
+	case bson.A:
+		return bsontype.Array
+	case primitive.ObjectID:
+		return bsontype.ObjectID
+	case bool:
+		return bsontype.Boolean
+	case primitive.DateTime:
+		return bsontype.DateTime
+	case primitive.Regex:
+		return bsontype.Regex
+	case int32:
+		return bsontype.Int32
+	case primitive.Timestamp:
+		return bsontype.Timestamp
+	case int64:
+		return bsontype.Int64
+	case primitive.Decimal128:
+		return bsontype.Decimal128
+	}
+	return 0
+}
+
+// matchType 判断值是否是指定的类型
+// 支持类型别名、类型编号、"number" 以及由它们组成的数组
+func matchType(v interface{}, cond interface{}) (bool, error) {
+	if arr, ok := cond.(bson.A); ok {
+		for _, c := range arr {
+			matched, err := matchType(v, c)
+			if err != nil || matched {
+				return matched, err
+			}
+		}
+		return false, nil
+	}
+	t := typeOf(v)
+	if alias, ok := cond.(string); ok {
+		if alias == "number" {
+			_, isNumber := toFloat(v)
+			return isNumber, nil
+		}
+		expected, ok := bsonTypes[alias]
+		if !ok {
+			return false, fmt.Errorf("memory: unknown $type %s", alias)
+		}
+		return t == expected, nil
+	}
+	n, ok := toFloat(cond)
+	if !ok {
+		return false, fmt.Errorf("memory: $type needs a type alias or number")
+	}
+	return t == bsontype.Type(n), nil
+}
@@ -0,0 +1,415 @@
+// Package memory 内存存储后端
+// 实现与 mongo 相同的数据表操作，便于在没有 mongod 的环境中运行测试
+//
+//	collections.SetDefaultStore(memory.NewStore())
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/r2day/collections"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// duplicateKeyCode mongo 重复键的错误码
+const duplicateKeyCode = 11000
+
+// Store 内存存储后端
+type Store struct {
+	mu          sync.Mutex
+	collections map[string]*Collection
+}
+
+// NewStore 创建内存存储后端
+func NewStore() *Store {
+	return &Store{collections: make(map[string]*Collection)}
+}
+
+// Collection 返回数据表，不存在时自动创建
+func (s *Store) Collection(name string) collections.Collection {
+	return s.collection(name)
+}
+
+// collection 返回数据表
+func (s *Store) collection(name string) *Collection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	coll, ok := s.collections[name]
+	if !ok {
+		coll = &Collection{name: name}
+		s.collections[name] = coll
+	}
+	return coll
+}
+
+// Drop 删除数据表
+func (s *Store) Drop(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.collections, name)
+}
+
+// Collection 内存数据表
+// 记录按照插入顺序保存
+type Collection struct {
+	name    string
+	mu      sync.RWMutex
+	docs    []bson.D
+	indexes []collections.Index
+}
+
+// toDoc 将任意值转换为 bson.D
+// 通过序列化保证与 mongo 中保存的类型一致
+func toDoc(v interface{}) (bson.D, error) {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	doc := bson.D{}
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// toRaw 将记录转换为 bson.Raw
+func toRaw(doc bson.D) (bson.Raw, error) {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return bson.Raw(data), nil
+}
+
+// idOf 返回记录的 _id
+func idOf(doc bson.D) (interface{}, bool) {
+	for _, e := range doc {
+		if e.Key == "_id" {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// duplicateKeyError 返回与 mongo 相同的重复键错误
+func (c *Collection) duplicateKeyError(index string, key bson.D) error {
+	return mongo.WriteException{
+		WriteErrors: mongo.WriteErrors{{
+			Code: duplicateKeyCode,
+			Message: fmt.Sprintf("E11000 duplicate key error collection: %s index: %s dup key: %s",
+				c.name, index, formatKey(key)),
+		}},
+	}
+}
+
+// formatKey 格式化重复键信息
+func formatKey(key bson.D) string {
+	parts := make([]string, 0, len(key))
+	for _, e := range key {
+		parts = append(parts, fmt.Sprintf("%s: %v", e.Key, e.Value))
+	}
+	return "{ " + strings.Join(parts, ", ") + " }"
+}
+
+// InsertOne 插入一条记录
+// 未指定 _id 时自动生成
+func (c *Collection) InsertOne(ctx context.Context, v interface{}) (*mongo.InsertOneResult, error) {
+	doc, err := toDoc(v)
+	if err != nil {
+		return nil, err
+	}
+	id, ok := idOf(doc)
+	if !ok {
+		id = primitive.NewObjectID()
+		doc = append(bson.D{{Key: "_id", Value: id}}, doc...)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, existing := range c.docs {
+		if existingID, _ := idOf(existing); equal(existingID, id) {
+			return nil, c.duplicateKeyError("_id_", bson.D{{Key: "_id", Value: id}})
+		}
+	}
+	docs := append(append([]bson.D{}, c.docs...), doc)
+	if err := c.checkUnique(docs, []int{len(docs) - 1}); err != nil {
+		return nil, err
+	}
+	c.docs = docs
+	return &mongo.InsertOneResult{InsertedID: id}, nil
+}
+
+// filter 返回满足过滤条件的记录下标
+func (c *Collection) filter(filter bson.D) ([]int, error) {
+	normalized, err := toDoc(filter)
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]int, 0)
+	for i, doc := range c.docs {
+		ok, err := match(doc, normalized)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, i)
+		}
+	}
+	return matched, nil
+}
+
+// FindOne 查找一条记录
+func (c *Collection) FindOne(ctx context.Context, filter bson.D) (bson.Raw, error) {
+	results, err := c.Find(ctx, filter, &collections.FindOptions{Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+	return results[0], nil
+}
+
+// Find 查找记录
+func (c *Collection) Find(ctx context.Context, filter bson.D, opts *collections.FindOptions) ([]bson.Raw, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	indexes, err := c.filter(filter)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]bson.D, 0, len(indexes))
+	for _, i := range indexes {
+		docs = append(docs, c.docs[i])
+	}
+
+	if opts != nil {
+		if len(opts.Sort) > 0 {
+			sortDocs(docs, opts.Sort)
+		}
+		if opts.Skip > 0 {
+			if opts.Skip >= int64(len(docs)) {
+				docs = docs[:0]
+			} else {
+				docs = docs[opts.Skip:]
+			}
+		}
+		limit := opts.Limit
+		if limit < 0 {
+			limit = -limit
+		}
+		if limit > 0 && limit < int64(len(docs)) {
+			docs = docs[:limit]
+		}
+	}
+
+	results := make([]bson.Raw, 0, len(docs))
+	for _, doc := range docs {
+		if opts != nil && len(opts.Projection) > 0 {
+			if doc, err = project(doc, opts.Projection); err != nil {
+				return nil, err
+			}
+		}
+		raw, err := toRaw(doc)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, raw)
+	}
+	return results, nil
+}
+
+// project 返回记录中指定的字段
+// 只支持包含字段的形式，_id 默认返回
+func project(doc bson.D, projection bson.D) (bson.D, error) {
+	projected := bson.D{}
+	includeID := true
+	for _, p := range projection {
+		include, ok := toFloat(normalizeNumber(p.Value))
+		if b, isBool := p.Value.(bool); isBool {
+			include, ok = 0, true
+			if b {
+				include = 1
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("memory: unsupported projection %s", p.Key)
+		}
+		if p.Key == "_id" {
+			includeID = include != 0
+			continue
+		}
+		if include == 0 {
+			return nil, fmt.Errorf("memory: exclusion projection is not supported")
+		}
+		parts := strings.Split(p.Key, ".")
+		if v, found := getPath(doc, parts); found {
+			var err error
+			if projected, err = setPath(projected, parts, clone(v)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if id, ok := idOf(doc); ok && includeID {
+		projected = append(bson.D{{Key: "_id", Value: id}}, projected...)
+	}
+	return projected, nil
+}
+
+// sortDocs 按照排序规则进行排序
+// 缺失的字段视为 null，排在最前面
+func sortDocs(docs []bson.D, spec bson.D) {
+	sort.SliceStable(docs, func(i, j int) bool {
+		for _, s := range spec {
+			parts := strings.Split(s.Key, ".")
+			a, _ := getPath(docs[i], parts)
+			b, _ := getPath(docs[j], parts)
+			c := compare(a, b)
+			if c == 0 {
+				continue
+			}
+			if direction, _ := toFloat(normalizeNumber(s.Value)); direction < 0 {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+// normalizeNumber 将 go 的整数类型转换为 bson 中的数字类型
+func normalizeNumber(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int8:
+		return int32(n)
+	case int16:
+		return int32(n)
+	case uint8:
+		return int32(n)
+	case uint16:
+		return int32(n)
+	case uint32:
+		return int64(n)
+	case uint:
+		return int64(n)
+	case float32:
+		return float64(n)
+	}
+	return v
+}
+
+// CountDocuments 获取记录总数
+func (c *Collection) CountDocuments(ctx context.Context, filter bson.D) (int64, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	indexes, err := c.filter(filter)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(indexes)), nil
+}
+
+// UpdateOne 更新一条记录
+func (c *Collection) UpdateOne(ctx context.Context, filter bson.D, update bson.D) (*mongo.UpdateResult, error) {
+	return c.update(filter, update, false)
+}
+
+// UpdateMany 更新所有满足条件的记录
+func (c *Collection) UpdateMany(ctx context.Context, filter bson.D, update bson.D) (*mongo.UpdateResult, error) {
+	return c.update(filter, update, true)
+}
+
+// update 更新记录
+// 任意一条记录更新失败时不修改任何记录
+func (c *Collection) update(filter bson.D, update bson.D, multi bool) (*mongo.UpdateResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	indexes, err := c.filter(filter)
+	if err != nil {
+		return nil, err
+	}
+	result := &mongo.UpdateResult{}
+	if len(indexes) == 0 {
+		return result, nil
+	}
+	if !multi {
+		indexes = indexes[:1]
+	}
+
+	normalized, err := toDoc(update)
+	if err != nil {
+		return nil, err
+	}
+	updates := make([]bson.D, len(indexes))
+	for n, i := range indexes {
+		if updates[n], err = applyUpdate(c.docs[i], normalized); err != nil {
+			return nil, err
+		}
+	}
+	docs := append([]bson.D{}, c.docs...)
+	for n, i := range indexes {
+		docs[i] = updates[n]
+	}
+	if err := c.checkUnique(docs, indexes); err != nil {
+		return nil, err
+	}
+	for n, i := range indexes {
+		result.MatchedCount++
+		if !equal(updates[n], c.docs[i]) {
+			result.ModifiedCount++
+		}
+		c.docs[i] = updates[n]
+	}
+	return result, nil
+}
+
+// DeleteOne 删除一条记录
+func (c *Collection) DeleteOne(ctx context.Context, filter bson.D) (*mongo.DeleteResult, error) {
+	return c.delete(filter, false)
+}
+
+// DeleteMany 删除所有满足条件的记录
+func (c *Collection) DeleteMany(ctx context.Context, filter bson.D) (*mongo.DeleteResult, error) {
+	return c.delete(filter, true)
+}
+
+// delete 删除记录
+func (c *Collection) delete(filter bson.D, multi bool) (*mongo.DeleteResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	indexes, err := c.filter(filter)
+	if err != nil {
+		return nil, err
+	}
+	result := &mongo.DeleteResult{}
+	if len(indexes) == 0 {
+		return result, nil
+	}
+	if !multi {
+		indexes = indexes[:1]
+	}
+	removed := make(map[int]bool, len(indexes))
+	for _, i := range indexes {
+		removed[i] = true
+	}
+	kept := make([]bson.D, 0, len(c.docs)-len(indexes))
+	for i, doc := range c.docs {
+		if !removed[i] {
+			kept = append(kept, doc)
+		}
+	}
+	c.docs = kept
+	result.DeletedCount = int64(len(indexes))
+	return result, nil
+}
@@ -0,0 +1,309 @@
+package memory
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// applyUpdate 对记录执行更新语句
+// 返回更新后的记录，原记录保持不变
+func applyUpdate(doc bson.D, update bson.D) (bson.D, error) {
+	updated := clone(doc).(bson.D)
+	for _, op := range update {
+		fields, ok := op.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("memory: %s needs a document", op.Key)
+		}
+		for _, f := range fields {
+			var err error
+			parts := strings.Split(f.Key, ".")
+			if parts[0] == "_id" {
+				if op.Key == "$set" && equalPath(updated, parts, f.Value) {
+					continue
+				}
+				return nil, fmt.Errorf("memory: the field '_id' is immutable")
+			}
+			switch op.Key {
+			case "$set":
+				updated, err = setPath(updated, parts, clone(f.Value))
+			case "$unset":
+				updated = unsetPath(updated, parts)
+			case "$inc":
+				updated, err = incPath(updated, parts, f.Value)
+			case "$push":
+				updated, err = pushPath(updated, parts, f.Value)
+			case "$addToSet":
+				updated, err = addToSetPath(updated, parts, f.Value)
+			case "$pull":
+				updated, err = pullPath(updated, parts, f.Value)
+			default:
+				err = fmt.Errorf("memory: unsupported update operator %s", op.Key)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return updated, nil
+}
+
+// equalPath 判断路径上的值是否等于指定值
+func equalPath(doc bson.D, parts []string, v interface{}) bool {
+	values, found := lookup(doc, parts)
+	return found && len(values) == 1 && equal(values[0], v)
+}
+
+// getPath 获取路径上的值（不展开数组）
+func getPath(doc bson.D, parts []string) (interface{}, bool) {
+	var cur interface{} = doc
+	for _, p := range parts {
+		switch c := cur.(type) {
+		case bson.D:
+			found := false
+			for _, e := range c {
+				if e.Key == p {
+					cur, found = e.Value, true
+					break
+				}
+			}
+			if !found {
+				return nil, false
+			}
+		case bson.A:
+			i, err := strconv.Atoi(p)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, false
+			}
+			cur = c[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// setPath 设置路径上的值，中间缺失的文档会被创建
+func setPath(doc bson.D, parts []string, v interface{}) (bson.D, error) {
+	for i, e := range doc {
+		if e.Key != parts[0] {
+			continue
+		}
+		if len(parts) == 1 {
+			doc[i].Value = v
+			return doc, nil
+		}
+		switch child := e.Value.(type) {
+		case bson.D:
+			updated, err := setPath(child, parts[1:], v)
+			doc[i].Value = updated
+			return doc, err
+		case bson.A:
+			idx, err := strconv.Atoi(parts[1])
+			if err != nil || idx < 0 {
+				return nil, fmt.Errorf("memory: cannot create field %s in array", parts[1])
+			}
+			for len(child) <= idx {
+				child = append(child, nil)
+			}
+			if len(parts) == 2 {
+				child[idx] = v
+			} else {
+				sub, _ := child[idx].(bson.D)
+				if sub == nil {
+					sub = bson.D{}
+				}
+				if child[idx], err = setPath(sub, parts[2:], v); err != nil {
+					return nil, err
+				}
+			}
+			doc[i].Value = child
+			return doc, nil
+		case nil:
+			updated, err := setPath(bson.D{}, parts[1:], v)
+			doc[i].Value = updated
+			return doc, err
+		default:
+			return nil, fmt.Errorf("memory: cannot create field %s in %s", parts[1], parts[0])
+		}
+	}
+	if len(parts) == 1 {
+		return append(doc, bson.E{Key: parts[0], Value: v}), nil
+	}
+	child, err := setPath(bson.D{}, parts[1:], v)
+	if err != nil {
+		return nil, err
+	}
+	return append(doc, bson.E{Key: parts[0], Value: child}), nil
+}
+
+// unsetPath 删除路径上的值
+func unsetPath(doc bson.D, parts []string) bson.D {
+	for i, e := range doc {
+		if e.Key != parts[0] {
+			continue
+		}
+		if len(parts) == 1 {
+			return append(doc[:i:i], doc[i+1:]...)
+		}
+		if child, ok := e.Value.(bson.D); ok {
+			doc[i].Value = unsetPath(child, parts[1:])
+		}
+		return doc
+	}
+	return doc
+}
+
+// incPath 对路径上的数字进行累加
+func incPath(doc bson.D, parts []string, delta interface{}) (bson.D, error) {
+	if _, ok := toFloat(delta); !ok {
+		return nil, fmt.Errorf("memory: cannot $inc with non-numeric value")
+	}
+	cur, found := getPath(doc, parts)
+	if !found || cur == nil {
+		return setPath(doc, parts, delta)
+	}
+	sum, err := add(cur, delta)
+	if err != nil {
+		return nil, fmt.Errorf("memory: cannot $inc field %s: %w", strings.Join(parts, "."), err)
+	}
+	return setPath(doc, parts, sum)
+}
+
+// add 数字相加
+// 整数相加保持整数类型，溢出时转换为 int64
+func add(a, b interface{}) (interface{}, error) {
+	switch av := a.(type) {
+	case int32:
+		switch bv := b.(type) {
+		case int32:
+			sum := int64(av) + int64(bv)
+			if sum >= math.MinInt32 && sum <= math.MaxInt32 {
+				return int32(sum), nil
+			}
+			return sum, nil
+		case int64:
+			return int64(av) + bv, nil
+		}
+	case int64:
+		switch bv := b.(type) {
+		case int32:
+			return av + int64(bv), nil
+		case int64:
+			return av + bv, nil
+		}
+	}
+	af, aok := toFloat(a)
+	bf, bok := toFloat(b)
+	if !aok || !bok {
+		return nil, fmt.Errorf("non-numeric value")
+	}
+	return af + bf, nil
+}
+
+// arrayAt 返回路径上的数组
+func arrayAt(doc bson.D, parts []string) (bson.A, error) {
+	cur, found := getPath(doc, parts)
+	if !found || cur == nil {
+		return bson.A{}, nil
+	}
+	arr, ok := cur.(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("memory: field %s is not an array", strings.Join(parts, "."))
+	}
+	return arr, nil
+}
+
+// eachValues 返回 $each 中的值
+func eachValues(v interface{}) bson.A {
+	if d, ok := v.(bson.D); ok && len(d) > 0 && d[0].Key == "$each" {
+		if each, ok := d[0].Value.(bson.A); ok {
+			return each
+		}
+	}
+	return bson.A{v}
+}
+
+// pushPath 向数组中追加元素
+func pushPath(doc bson.D, parts []string, v interface{}) (bson.D, error) {
+	arr, err := arrayAt(doc, parts)
+	if err != nil {
+		return nil, err
+	}
+	arr = append(bson.A{}, arr...)
+	for _, elem := range eachValues(v) {
+		arr = append(arr, clone(elem))
+	}
+	return setPath(doc, parts, arr)
+}
+
+// addToSetPath 向数组中追加不存在的元素
+func addToSetPath(doc bson.D, parts []string, v interface{}) (bson.D, error) {
+	arr, err := arrayAt(doc, parts)
+	if err != nil {
+		return nil, err
+	}
+	arr = append(bson.A{}, arr...)
+	for _, elem := range eachValues(v) {
+		exists := false
+		for _, cur := range arr {
+			if equal(cur, elem) {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			arr = append(arr, clone(elem))
+		}
+	}
+	return setPath(doc, parts, arr)
+}
+
+// pullPath 从数组中移除满足条件的元素
+func pullPath(doc bson.D, parts []string, cond interface{}) (bson.D, error) {
+	cur, found := getPath(doc, parts)
+	if !found || cur == nil {
+		return doc, nil
+	}
+	arr, ok := cur.(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("memory: field %s is not an array", strings.Join(parts, "."))
+	}
+	kept := bson.A{}
+	_, isDoc := cond.(bson.D)
+	for _, elem := range arr {
+		matched := equal(elem, cond)
+		if !matched && isDoc {
+			var err error
+			if matched, err = matchElem(elem, cond); err != nil {
+				return nil, err
+			}
+		}
+		if !matched {
+			kept = append(kept, elem)
+		}
+	}
+	return setPath(doc, parts, kept)
+}
+
+// clone 深拷贝值
+func clone(v interface{}) interface{} {
+	switch c := v.(type) {
+	case bson.D:
+		d := make(bson.D, len(c))
+		for i, e := range c {
+			d[i] = bson.E{Key: e.Key, Value: clone(e.Value)}
+		}
+		return d
+	case bson.A:
+		a := make(bson.A, len(c))
+		for i, e := range c {
+			a[i] = clone(e)
+		}
+		return a
+	}
+	return v
+}
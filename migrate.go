@@ -0,0 +1,250 @@
+package collections
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// migrateBatchSize 每批迁移的记录数
+const migrateBatchSize = 500
+
+// MigrationResult 迁移结果
+type MigrationResult struct {
+	// 已转换的记录数
+	Converted int64
+	// 无法转换的记录id
+	Failed []string
+}
+
+// systemTimeFields 由服务器生成的时间字段
+// 升级之前按照服务器的本地时间写入，迁移时按照 SystemLocation 解析
+var systemTimeFields = []string{"created_at", "updated_at", deletedAtField}
+
+// MigrateTimeFields 将字符串格式的时间转换为 BSON 时间
+// 创建时间、更新时间等系统时间按照 SystemLocation 解析，
+// 其他业务时间（例如下单时间）按照记录所属商户的时区解析，空字符串会被删除
+// 无法解析的记录保持不变并记录在 Failed 中，可以重复执行
+func MigrateTimeFields(ctx context.Context, coll Collection, fields ...string) (*MigrationResult, error) {
+	return migrateFields(ctx, coll, fields, "string", func(raw bson.Raw) (bool, error) {
+		return migrateTimes(ctx, coll, raw, fields)
+	})
+}
+
+// migrateFields 分批转换字段类型为 types 的记录
+func migrateFields(ctx context.Context, coll Collection, fields []string, types interface{}, convert func(raw bson.Raw) (bool, error)) (*MigrationResult, error) {
+	result := &MigrationResult{Failed: make([]string, 0)}
+	if len(fields) == 0 {
+		return result, nil
+	}
+	or := make(bson.A, 0, len(fields))
+	for _, f := range fields {
+		or = append(or, bson.D{{Key: f, Value: bson.D{{Key: "$type", Value: types}}}})
+	}
+
+	var last interface{}
+	for {
+		filter := bson.D{{Key: "$or", Value: or}}
+		if last != nil {
+			filter = append(filter, bson.E{Key: idField, Value: bson.D{{Key: "$gt", Value: last}}})
+		}
+		raws, err := coll.Find(ctx, filter, &FindOptions{Sort: bson.D{{Key: idField, Value: 1}}, Limit: migrateBatchSize})
+		if err != nil {
+			return result, err
+		}
+		for _, raw := range raws {
+			id := raw.Lookup(idField)
+			if err := id.Unmarshal(&last); err != nil {
+				return result, err
+			}
+			converted, err := convert(raw)
+			if err != nil {
+				log.WithField("id", id.String()).Error(err)
+				result.Failed = append(result.Failed, idString(last))
+				continue
+			}
+			if converted {
+				result.Converted++
+			}
+		}
+		if len(raws) < migrateBatchSize {
+			return result, nil
+		}
+	}
+}
+
+// migrateTimes 转换一条记录中的时间字段
+func migrateTimes(ctx context.Context, coll Collection, raw bson.Raw, fields []string) (bool, error) {
+	set, unset, err := convertTimes(raw, fields)
+	if err != nil {
+		return false, err
+	}
+	return migrateSet(ctx, coll, raw, set, unset)
+}
+
+// convertTimes 返回一条记录中字符串格式的时间转换后的取值以及需要删除的空字符串
+func convertTimes(raw bson.Raw, fields []string) (bson.D, bson.D, error) {
+	merchantID, _ := raw.Lookup("merchant_id").StringValueOK()
+	set := bson.D{}
+	unset := bson.D{}
+	for _, f := range fields {
+		val, ok := raw.Lookup(strings.Split(f, ".")...).StringValueOK()
+		if !ok {
+			continue
+		}
+		if val == "" {
+			unset = append(unset, bson.E{Key: f, Value: ""})
+			continue
+		}
+		t, err := ParseTime(val, timeLocation(f, merchantID))
+		if err != nil {
+			return nil, nil, err
+		}
+		set = append(set, bson.E{Key: f, Value: t})
+	}
+	return set, unset, nil
+}
+
+// ConvertTimeFields 返回字符串格式的时间转换为 BSON 时间后的记录
+// 与 MigrateTimeFields 的规则相同，空字符串转换为 null，只在内存中转换，不修改数据库中的记录
+func ConvertTimeFields(raw bson.Raw, fields ...string) (bson.Raw, error) {
+	set, unset, err := convertTimes(raw, fields)
+	if err != nil || (len(set) == 0 && len(unset) == 0) {
+		return raw, err
+	}
+	doc := bson.M{}
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	for _, e := range set {
+		setPath(doc, strings.Split(e.Key, "."), e.Value)
+	}
+	for _, e := range unset {
+		setPath(doc, strings.Split(e.Key, "."), nil)
+	}
+	return bson.Marshal(doc)
+}
+
+// timeLocation 返回解析字符串时间使用的时区
+func timeLocation(field string, merchantID string) *time.Location {
+	if contains(systemTimeFields, field) {
+		return SystemLocation()
+	}
+	return MerchantLocation(merchantID)
+}
+
+// migrateSet 更新一条记录中转换后的字段
+func migrateSet(ctx context.Context, coll Collection, raw bson.Raw, set bson.D, unset bson.D) (bool, error) {
+	if len(set) == 0 && len(unset) == 0 {
+		return false, nil
+	}
+	update := bson.D{}
+	if len(set) > 0 {
+		update = append(update, bson.E{Key: "$set", Value: set})
+	}
+	if len(unset) > 0 {
+		update = append(update, bson.E{Key: "$unset", Value: unset})
+	}
+	filter := bson.D{{Key: idField, Value: raw.Lookup(idField)}}
+	if _, err := coll.UpdateOne(ctx, filter, update); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// moneyTypes 历史数据中金额的类型
+var moneyTypes = bson.A{"double", "int", "long", "decimal", "string"}
+
+// MigrateMoneyFields 将浮点数、整数或者字符串格式的金额转换为 Money
+// 金额按照 currency 解析，小数位数超过币种精度的记录保持不变并记录在 Failed 中，可以重复执行
+// 只支持非数组中的字段，例如 assets.balance
+func MigrateMoneyFields(ctx context.Context, coll Collection, currency string, fields ...string) (*MigrationResult, error) {
+	return migrateFields(ctx, coll, fields, moneyTypes, func(raw bson.Raw) (bool, error) {
+		return migrateMoney(ctx, coll, raw, currency, fields)
+	})
+}
+
+// migrateMoney 转换一条记录中的金额字段
+func migrateMoney(ctx context.Context, coll Collection, raw bson.Raw, currency string, fields []string) (bool, error) {
+	set := bson.D{}
+	for _, f := range fields {
+		rv, err := raw.LookupErr(strings.Split(f, ".")...)
+		if err != nil || rv.Type == bsontype.EmbeddedDocument {
+			continue
+		}
+		m, err := legacyMoney(rv, currency)
+		if err != nil {
+			return false, err
+		}
+		set = append(set, bson.E{Key: f, Value: m})
+	}
+	return migrateSet(ctx, coll, raw, set, nil)
+}
+
+// idString 返回id的字符串形式
+func idString(id interface{}) string {
+	if objID, ok := id.(primitive.ObjectID); ok {
+		return objID.Hex()
+	}
+	return fmt.Sprint(id)
+}
+
+// timeFields 返回模型中的时间字段
+func (r *Repository[T, PT]) timeFields() []string {
+	fields := []string{"created_at", "updated_at", deletedAtField}
+	for _, f := range r.conf.FilterFields {
+		if f.Type == TimeField {
+			fields = append(fields, f.Key)
+		}
+	}
+	return fields
+}
+
+// moneyFields 返回模型中的金额字段
+func (r *Repository[T, PT]) moneyFields() []string {
+	fields := append([]string{}, r.conf.MoneyFields...)
+	for _, f := range r.conf.FilterFields {
+		if f.Type == MoneyField && !contains(fields, f.Key) {
+			fields = append(fields, f.Key)
+		}
+	}
+	return fields
+}
+
+// contains 判断列表中是否包含指定的值
+func contains(list []string, val string) bool {
+	for _, v := range list {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
+
+// MigrateMoney 将模型中历史格式的金额转换为 Money
+// 包括 WithMoneyFields 以及 MoneyFilter 声明的字段
+func (r *Repository[T, PT]) MigrateMoney(ctx context.Context, currency string) (*MigrationResult, error) {
+	result, err := MigrateMoneyFields(ctx, r.collection(), currency, r.moneyFields()...)
+	if err != nil {
+		log.WithField("collection", r.conf.CollectionName).Error(err)
+		return result, err
+	}
+	return result, nil
+}
+
+// MigrateTimes 将模型中字符串格式的时间转换为 BSON 时间
+// 包括 created_at、updated_at、deleted_at 以及声明为 TimeFilter 的字段
+func (r *Repository[T, PT]) MigrateTimes(ctx context.Context) (*MigrationResult, error) {
+	result, err := MigrateTimeFields(ctx, r.collection(), r.timeFields()...)
+	if err != nil {
+		log.WithField("collection", r.conf.CollectionName).Error(err)
+		return result, err
+	}
+	return result, nil
+}
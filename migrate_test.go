@@ -0,0 +1,59 @@
+package collections_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/r2day/collections"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMigrateTimes(t *testing.T) {
+	tokyo := time.FixedZone("tokyo", 9*3600)
+	server := time.FixedZone("server", 8*3600)
+	collections.SetMerchantLocation(merchantA.MerchantID, tokyo)
+	collections.SetSystemLocation(server)
+	t.Cleanup(func() {
+		collections.SetMerchantLocation(merchantA.MerchantID, nil)
+		collections.SetSystemLocation(nil)
+	})
+
+	repo := newRepo(collections.WithFilterFields(collections.TimeFilter("order_time")))
+	ctx := context.Background()
+	coll := repo.Store().Collection(repo.CollectionName())
+	_, err := coll.InsertOne(ctx, bson.D{
+		{Key: "merchant_id", Value: merchantA.MerchantID},
+		{Key: "created_at", Value: "2023-01-02 10:00:00"},
+		{Key: "updated_at", Value: ""},
+		{Key: "order_time", Value: "2023-01-02 10:00:00"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := repo.MigrateTimes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Converted != 1 || len(result.Failed) != 0 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	raw, err := coll.FindOne(ctx, bson.D{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := raw.Lookup("created_at").Time(); !got.Equal(time.Date(2023, 1, 2, 2, 0, 0, 0, time.UTC)) {
+		t.Fatalf("created_at should be parsed in the system location, got %v", got)
+	}
+	if got := raw.Lookup("order_time").Time(); !got.Equal(time.Date(2023, 1, 2, 1, 0, 0, 0, time.UTC)) {
+		t.Fatalf("order_time should be parsed in the merchant location, got %v", got)
+	}
+	if _, err := raw.LookupErr("updated_at"); err == nil {
+		t.Fatal("empty updated_at should be removed")
+	}
+
+	if result, err = repo.MigrateTimes(ctx); err != nil || result.Converted != 0 {
+		t.Fatalf("migration should be idempotent, got %+v %v", result, err)
+	}
+}
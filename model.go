@@ -0,0 +1,55 @@
+package collections
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BaseModel 基本的数据库模型字段
+// 一般情况所有model都应该包含如下字段
+// 通过内嵌的方式引入，序列化时字段会被展开
+type BaseModel struct {
+	// 创建时（用户上传的数据为空，所以默认可以不传该值)
+	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	// 商户号
+	// 如果用户部署为单机模式，则商户号为固定值
+	// 在其他模式下，MerchantID 起到命名空间的作用
+	MerchantID string `json:"merchant_id" bson:"merchant_id"`
+	// 账号id
+	// 账号表中为账号自身的标识，其他表中为关联的账号，创建时不会被修改
+	AccountID string `json:"account_id" bson:"account_id"`
+	// 创建者
+	// context 中有操作者的访问范围时写入操作者的账号id，忽略客户端传入的值
+	CreatedBy string `json:"created_by,omitempty" bson:"created_by,omitempty"`
+	// 创建时间
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	// 更新时间
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+	// 状态
+	Status bool `json:"status"`
+	// 访问级别
+	// context 中有操作者的访问范围时，创建时写入操作者的级别，更新时不能超过操作者的级别
+	AccessLevel AccessLevel `json:"access_level" bson:"access_level"`
+	// 版本号
+	// 创建时为 1，每次更新加 1，用于乐观锁
+	Version int64 `json:"version" bson:"version"`
+	// 删除时间
+	// 开启软删除时有效，为空表示未删除
+	DeletedAt *time.Time `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+	// 删除者
+	DeletedBy string `json:"deleted_by,omitempty" bson:"deleted_by,omitempty"`
+}
+
+// GetBaseModel 返回基本字段
+// 内嵌 BaseModel 的模型自动拥有该方法
+func (m *BaseModel) GetBaseModel() *BaseModel {
+	return m
+}
+
+// Document 仓库可以操作的模型
+// PT 为模型的指针类型，需要内嵌 BaseModel
+type Document[T any] interface {
+	*T
+	GetBaseModel() *BaseModel
+}
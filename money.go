@@ -0,0 +1,315 @@
+package collections
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	cerrors "github.com/r2day/collections/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// DefaultCurrency 默认币种
+const DefaultCurrency = "CNY"
+
+// moneyUnitsField 金额中最小货币单位的字段
+const moneyUnitsField = "units"
+
+// moneyCurrencyField 金额中币种的字段
+const moneyCurrencyField = "currency"
+
+// currencyExponents 币种的小数位数，未列出的币种为 2 位
+var currencyExponents = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+	"VND": 0,
+	"BHD": 3,
+	"KWD": 3,
+	"OMR": 3,
+}
+
+// moneySymbols 解析时忽略的货币符号
+var moneySymbols = []string{"¥", "￥", "$", "元"}
+
+// Money 金额
+// 以最小货币单位（例如分）保存，避免浮点数的精度问题
+// 数据库中保存为 {units: 1250, currency: "CNY"}，JSON 中金额为字符串 "12.50"
+type Money struct {
+	// 金额，以最小货币单位表示
+	Units int64 `json:"-" bson:"units"`
+	// 币种，ISO 4217 代码，为空时使用默认币种
+	Currency string `json:"currency" bson:"currency"`
+}
+
+// NewMoney 返回以最小货币单位表示的金额
+func NewMoney(units int64, currency string) Money {
+	return Money{Units: units, Currency: strings.ToUpper(currency)}
+}
+
+// ParseMoney 解析金额
+// 支持 12.5、-3.20、¥1,234.56、1.2E+3、USD 12.50 等格式，空字符串表示 0
+// 金额中的币种与 currency 不一致时返回 ErrValidation，currency 为空时使用金额中的币种
+// 小数位数超过币种精度时返回 ErrValidation，不会四舍五入
+func ParseMoney(val string, currency string) (Money, error) {
+	m := Money{Currency: strings.ToUpper(currency)}
+	s, code := splitCurrency(strings.TrimSpace(val))
+	if code != "" {
+		if m.Currency != "" && m.Currency != code {
+			return m, fmt.Errorf("%w: currency mismatch %s and %s", cerrors.ErrValidation, code, m.Currency)
+		}
+		m.Currency = code
+	}
+	for _, symbol := range moneySymbols {
+		s = strings.ReplaceAll(s, symbol, "")
+	}
+	s = strings.ReplaceAll(s, ",", "")
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return m, nil
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || strings.Contains(s, "/") {
+		return m, fmt.Errorf("%w: invalid amount %s", cerrors.ErrValidation, val)
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(m.exponent())), nil)
+	r.Mul(r, new(big.Rat).SetInt(scale))
+	if !r.IsInt() {
+		return m, fmt.Errorf("%w: amount %s exceeds the precision of %s", cerrors.ErrValidation, val, m.currency())
+	}
+	if !r.Num().IsInt64() {
+		return m, fmt.Errorf("%w: amount %s overflows", cerrors.ErrValidation, val)
+	}
+	m.Units = r.Num().Int64()
+	return m, nil
+}
+
+// splitCurrency 拆分金额前后以空格分隔的币种代码，例如 USD 12.50、12.50 USD
+func splitCurrency(s string) (string, string) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return s, ""
+	}
+	if isCurrencyCode(fields[0]) {
+		return fields[1], strings.ToUpper(fields[0])
+	}
+	if isCurrencyCode(fields[1]) {
+		return fields[0], strings.ToUpper(fields[1])
+	}
+	return s, ""
+}
+
+// isCurrencyCode 判断是否是 ISO 4217 形式的币种代码
+func isCurrencyCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, c := range s {
+		if (c < 'A' || c > 'Z') && (c < 'a' || c > 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+// MustParseMoney 解析金额，失败时 panic
+// 一般用于常量
+func MustParseMoney(val string, currency string) Money {
+	m, err := ParseMoney(val, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// currency 返回币种
+func (m Money) currency() string {
+	if m.Currency == "" {
+		return DefaultCurrency
+	}
+	return m.Currency
+}
+
+// exponent 返回币种的小数位数
+func (m Money) exponent() int {
+	if exp, ok := currencyExponents[m.currency()]; ok {
+		return exp
+	}
+	return 2
+}
+
+// check 检查两个金额的币种是否一致
+func (m Money) check(o Money) error {
+	if m.currency() != o.currency() {
+		return fmt.Errorf("%w: currency mismatch %s and %s", cerrors.ErrValidation, m.currency(), o.currency())
+	}
+	return nil
+}
+
+// IsZero 金额是否为 0
+func (m Money) IsZero() bool {
+	return m.Units == 0
+}
+
+// IsNegative 金额是否为负数
+func (m Money) IsNegative() bool {
+	return m.Units < 0
+}
+
+// Add 加法
+// 币种不一致或者溢出时返回 ErrValidation
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.check(o); err != nil {
+		return Money{}, err
+	}
+	units := m.Units + o.Units
+	if (o.Units > 0 && units < m.Units) || (o.Units < 0 && units > m.Units) {
+		return Money{}, fmt.Errorf("%w: amount overflows", cerrors.ErrValidation)
+	}
+	return Money{Units: units, Currency: m.currency()}, nil
+}
+
+// Sub 减法
+// 币种不一致或者溢出时返回 ErrValidation
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Units == math.MinInt64 {
+		return Money{}, fmt.Errorf("%w: amount overflows", cerrors.ErrValidation)
+	}
+	return m.Add(o.Neg())
+}
+
+// Neg 返回相反数
+func (m Money) Neg() Money {
+	return Money{Units: -m.Units, Currency: m.Currency}
+}
+
+// Mul 乘以整数，例如单价乘以数量
+// 溢出时返回 ErrValidation
+func (m Money) Mul(n int64) (Money, error) {
+	units := new(big.Int).Mul(big.NewInt(m.Units), big.NewInt(n))
+	if !units.IsInt64() {
+		return Money{}, fmt.Errorf("%w: amount overflows", cerrors.ErrValidation)
+	}
+	return Money{Units: units.Int64(), Currency: m.currency()}, nil
+}
+
+// Cmp 比较两个金额
+// 小于、等于、大于时分别返回 -1、0、1，币种不一致时返回 ErrValidation
+func (m Money) Cmp(o Money) (int, error) {
+	if err := m.check(o); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Units < o.Units:
+		return -1, nil
+	case m.Units > o.Units:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// String 返回十进制形式的金额，例如 12.50
+func (m Money) String() string {
+	exp := m.exponent()
+	units := new(big.Int).Abs(big.NewInt(m.Units)).String()
+	if exp > 0 {
+		if len(units) <= exp {
+			units = strings.Repeat("0", exp-len(units)+1) + units
+		}
+		units = units[:len(units)-exp] + "." + units[len(units)-exp:]
+	}
+	if m.Units < 0 {
+		return "-" + units
+	}
+	return units
+}
+
+// moneyJSON 金额的 JSON 形式
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+// MarshalJSON 金额以字符串表示，避免前端的精度问题
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.String(), m.currency()})
+}
+
+// UnmarshalJSON 解析金额
+// 支持 {"amount": "12.50", "currency": "CNY"} 以及 "12.50"、12.5 的形式
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = []byte(strings.TrimSpace(string(data)))
+	if string(data) == "null" {
+		return nil
+	}
+	currency := m.Currency
+	amount := json.RawMessage(data)
+	if len(data) > 0 && data[0] == '{' {
+		v := moneyJSON{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		amount, currency = v.Amount, v.Currency
+	}
+	val := string(amount)
+	if len(amount) > 0 && amount[0] == '"' {
+		if err := json.Unmarshal(amount, &val); err != nil {
+			return err
+		}
+	}
+	parsed, err := ParseMoney(val, currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// UnmarshalBSONValue 解析金额
+// 兼容历史数据中以浮点数、整数或者字符串保存的金额，按照默认币种解析
+func (m *Money) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	rv := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bsontype.EmbeddedDocument:
+		type plain Money
+		v := plain{}
+		if err := rv.Unmarshal(&v); err != nil {
+			return err
+		}
+		*m = Money(v)
+		return nil
+	case bsontype.Null, bsontype.Undefined:
+		*m = Money{}
+		return nil
+	}
+	v, err := legacyMoney(rv, DefaultCurrency)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// legacyMoney 解析历史数据中以浮点数、整数或者字符串保存的金额
+// 浮点数按照最短的十进制形式解析，例如 12.5
+func legacyMoney(rv bson.RawValue, currency string) (Money, error) {
+	switch rv.Type {
+	case bsontype.Double:
+		return ParseMoney(strconv.FormatFloat(rv.Double(), 'f', -1, 64), currency)
+	case bsontype.Int32:
+		return ParseMoney(strconv.FormatInt(int64(rv.Int32()), 10), currency)
+	case bsontype.Int64:
+		return ParseMoney(strconv.FormatInt(rv.Int64(), 10), currency)
+	case bsontype.Decimal128:
+		return ParseMoney(rv.Decimal128().String(), currency)
+	case bsontype.String:
+		return ParseMoney(rv.StringValue(), currency)
+	}
+	return Money{}, fmt.Errorf("cannot decode %s into money", rv.Type)
+}
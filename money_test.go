@@ -0,0 +1,51 @@
+package collections_test
+
+import (
+	"testing"
+
+	"github.com/r2day/collections"
+	cerrors "github.com/r2day/collections/errors"
+)
+
+func TestParseMoney(t *testing.T) {
+	cases := []struct {
+		val      string
+		currency string
+		units    int64
+		want     string
+	}{
+		{"12.5", "", 1250, ""},
+		{"-3.20", "cny", -320, "CNY"},
+		{"¥1,234.56", "", 123456, ""},
+		{"1.2E+3", "", 120000, ""},
+		{"", "", 0, ""},
+		{"1000", "JPY", 1000, "JPY"},
+		{"1.234", "BHD", 1234, "BHD"},
+		{"USD 12.50", "", 1250, "USD"},
+		{"12.50 usd", "USD", 1250, "USD"},
+	}
+	for _, c := range cases {
+		m, err := collections.ParseMoney(c.val, c.currency)
+		if err != nil {
+			t.Fatalf("%s: %v", c.val, err)
+		}
+		if m.Units != c.units || m.Currency != c.want {
+			t.Fatalf("%s: expected %d %s, got %+v", c.val, c.units, c.want, m)
+		}
+	}
+
+	for _, c := range []struct {
+		val      string
+		currency string
+	}{
+		{"abc", ""},
+		{"1/2", ""},
+		{"1.005", ""},
+		{"1.5", "JPY"},
+		{"99999999999999999999", ""},
+		{"USD 1", "CNY"},
+	} {
+		_, err := collections.ParseMoney(c.val, c.currency)
+		expectError(t, err, cerrors.ErrValidation)
+	}
+}
@@ -0,0 +1,187 @@
+package collections
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	cerrors "github.com/r2day/collections/errors"
+	"github.com/r2day/rest"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Page 基于游标的分页结果
+// 适用于数据量持续增长的表，例如流水和日志
+type Page[PT any] struct {
+	// 数据列表
+	Items []PT
+	// 下一页的游标，为空表示没有更多数据
+	NextCursor string
+	// 总数，指定 WithoutTotal 时为 -1
+	Total int64
+}
+
+// cursor 游标内容
+// 记录排序字段以及最后一条记录对应的值
+type cursor struct {
+	Keys   []string `bson:"k"`
+	Values bson.A   `bson:"v"`
+}
+
+// encodeCursor 生成游标
+func encodeCursor(sort SortSpec, last bson.Raw) (string, error) {
+	c := cursor{Keys: make([]string, 0, len(sort)), Values: make(bson.A, 0, len(sort))}
+	for _, f := range sort {
+		var v interface{}
+		if rv, err := last.LookupErr(strings.Split(f.Key, ".")...); err == nil {
+			if err := rv.Unmarshal(&v); err != nil {
+				return "", err
+			}
+		}
+		c.Keys = append(c.Keys, sortKey(f))
+		c.Values = append(c.Values, v)
+	}
+	data, err := bson.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor 解析游标
+// 游标与当前排序规则不一致时返回 ErrValidation
+func decodeCursor(token string, sort SortSpec) (bson.A, error) {
+	invalid := fmt.Errorf("%w: invalid cursor", cerrors.ErrValidation)
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, invalid
+	}
+	c := cursor{}
+	if err := bson.Unmarshal(data, &c); err != nil {
+		return nil, invalid
+	}
+	if len(c.Keys) != len(sort) || len(c.Values) != len(sort) {
+		return nil, invalid
+	}
+	for i, f := range sort {
+		if c.Keys[i] != sortKey(f) {
+			return nil, invalid
+		}
+	}
+	return c.Values, nil
+}
+
+// sortKey 返回游标中记录的排序字段
+func sortKey(f SortField) string {
+	if f.Desc {
+		return "-" + f.Key
+	}
+	return f.Key
+}
+
+// keysetFilter 返回位于游标之后的记录的过滤条件
+// 例如排序为 a ASC, _id ASC 时
+// {$or: [{a: {$gt: va}}, {a: va, _id: {$gt: vid}}]}
+// mongo 中 null 以及不存在的字段排在所有值之前，$gt 以及 $lt 不会匹配这些记录，所以需要单独处理：
+// 正序时游标的值为 null 则之后是所有非 null 的记录；倒序时游标的值不为 null 则之后还包括 null 的记录
+// 同一个排序字段中除 null 以外的值需要是相同的类型
+func keysetFilter(sort SortSpec, values bson.A) bson.E {
+	or := make(bson.A, 0, len(sort))
+	for i, f := range sort {
+		cond := bson.D{}
+		for j := 0; j < i; j++ {
+			cond = append(cond, bson.E{Key: sort[j].Key, Value: values[j]})
+		}
+		after, ok := keysetAfter(f, values[i])
+		if !ok {
+			continue
+		}
+		or = append(or, append(cond, after))
+	}
+	return bson.E{Key: "$or", Value: or}
+}
+
+// keysetAfter 返回排序字段位于游标之后的条件
+// 倒序时游标的值为 null 则之后没有更大的值，返回 false
+func keysetAfter(f SortField, value interface{}) (bson.E, bool) {
+	switch {
+	case value == nil && f.Desc:
+		return bson.E{}, false
+	case value == nil:
+		return bson.E{Key: f.Key, Value: bson.D{{Key: "$ne", Value: nil}}}, true
+	case f.Desc:
+		return bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: f.Key, Value: bson.D{{Key: "$lt", Value: value}}}},
+			bson.D{{Key: f.Key, Value: nil}},
+		}}, true
+	}
+	return bson.E{Key: f.Key, Value: bson.D{{Key: "$gt", Value: value}}}, true
+}
+
+// GetPage 基于游标获取列表
+// 与 GetList 相同的过滤、排序以及访问级别规则，但是不使用 offset 跳过记录
+// token 为上一页返回的 NextCursor，为空时返回第一页
+func (r *Repository[T, PT]) GetPage(ctx context.Context, scope Scope, urlParams *rest.UrlParams, token string, opts ...ListOption) (*Page[PT], error) {
+	coll := r.collection()
+	listOpts := r.listOptions(urlParams, opts)
+	logCtx := log.WithField("merchantID", scope.MerchantID).WithField("urlParams.FilterMap", urlParams.FilterMap)
+	scope, err := actorScope(ctx, scope)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+	sort, err := r.resolveSort(listOpts.Sort)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+
+	filters, err := r.listFilter(scope, urlParams, listOpts)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+
+	total, err := r.count(ctx, filters, listOpts)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+
+	// 从游标之后开始查询
+	if token != "" {
+		values, err := decodeCursor(token, sort)
+		if err != nil {
+			logCtx.Error(err)
+			return nil, err
+		}
+		filters = append(filters, keysetFilter(sort, values))
+	}
+
+	limit := int64(urlParams.Range.Limit)
+	if limit <= 0 {
+		limit = rest.DefaultPerPage
+	}
+	// 多查询一条用于判断是否还有下一页
+	raws, err := coll.Find(ctx, filters, &FindOptions{Sort: sort.BSON(), Limit: limit + 1})
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+
+	page := &Page[PT]{Total: total}
+	if int64(len(raws)) > limit {
+		raws = raws[:limit]
+		if page.NextCursor, err = encodeCursor(sort, raws[len(raws)-1]); err != nil {
+			logCtx.Error(err)
+			return nil, err
+		}
+	}
+	if page.Items, err = r.decodeAll(raws); err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+	return page, nil
+}
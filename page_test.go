@@ -0,0 +1,101 @@
+package collections_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/r2day/collections"
+	cerrors "github.com/r2day/collections/errors"
+	"github.com/r2day/rest"
+)
+
+func TestSortAndKeysetPaging(t *testing.T) {
+	repo := newRepo()
+	for _, m := range []*item{{Name: "d", Qty: 2}, {Name: "a", Qty: 1}, {Name: "c", Qty: 2}, {Name: "b", Qty: 3}, {Name: "e", Qty: 1}} {
+		create(t, repo, merchantA, m)
+	}
+
+	p := params(nil)
+	p.Sort = rest.ReqSort{Key: "name", SortType: rest.AES}
+	list, _, err := repo.GetList(as(merchantA), merchantA, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(names(list), []string{"a", "b", "c", "d", "e"}) {
+		t.Fatalf("unexpected ascending order %v", names(list))
+	}
+	p.Sort.SortType = rest.DESC
+	if list, _, err = repo.GetList(as(merchantA), merchantA, p); err != nil {
+		t.Fatal(err)
+	}
+	if !equal(names(list), []string{"e", "d", "c", "b", "a"}) {
+		t.Fatalf("unexpected descending order %v", names(list))
+	}
+	p.Sort.Key = "note"
+	if _, _, err := repo.GetList(as(merchantA), merchantA, p); !errors.Is(err, cerrors.ErrValidation) {
+		t.Fatalf("undeclared sort field should be rejected, got %v", err)
+	}
+
+	spec, err := collections.ParseSort(`[["qty","DESC"],["name","ASC"]]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0)
+	token := ""
+	for i := 0; ; i++ {
+		page, err := repo.GetPage(as(merchantA), merchantA, &rest.UrlParams{Range: rest.ReqRange{Limit: 2}}, token, collections.SortBy(spec))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 5 {
+			t.Fatalf("unexpected total %d", page.Total)
+		}
+		got = append(got, names(page.Items)...)
+		if token = page.NextCursor; token == "" || i > 5 {
+			break
+		}
+	}
+	if !equal(got, []string{"b", "c", "d", "a", "e"}) {
+		t.Fatalf("unexpected keyset order %v", got)
+	}
+	if _, err := repo.GetPage(as(merchantA), merchantA, params(nil), "bogus"); !errors.Is(err, cerrors.ErrValidation) {
+		t.Fatalf("invalid cursor should be rejected, got %v", err)
+	}
+}
+
+func TestKeysetPagingWithNull(t *testing.T) {
+	repo := newRepo(collections.WithSortableFields("note"))
+	for _, m := range []*item{{Name: "1", Note: "b"}, {Name: "2"}, {Name: "3", Note: "a"}, {Name: "4"}, {Name: "5", Note: "c"}, {Name: "6"}} {
+		create(t, repo, merchantA, m)
+	}
+
+	for _, c := range []struct {
+		sort string
+		want []string
+	}{
+		{`["note","ASC"]`, []string{"2", "4", "6", "3", "1", "5"}},
+		{`["note","DESC"]`, []string{"5", "1", "3", "6", "4", "2"}},
+	} {
+		spec, err := collections.ParseSort(c.sort)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, limit := range []int{1, 2, 4} {
+			got := make([]string, 0)
+			token := ""
+			for i := 0; i < 10; i++ {
+				page, err := repo.GetPage(as(merchantA), merchantA, &rest.UrlParams{Range: rest.ReqRange{Limit: limit}}, token, collections.SortBy(spec))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, names(page.Items)...)
+				if token = page.NextCursor; token == "" {
+					break
+				}
+			}
+			if !equal(got, c.want) {
+				t.Fatalf("%s limit %d: expected %v, got %v", c.sort, limit, c.want, got)
+			}
+		}
+	}
+}
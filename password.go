@@ -0,0 +1,179 @@
+package collections
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	cerrors "github.com/r2day/collections/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordCost bcrypt 的计算强度
+// 强度低于该值的哈希值会在登录时重新计算
+var PasswordCost = bcrypt.DefaultCost
+
+// HashPassword 使用 bcrypt 计算密码的哈希值
+// 传入的总是作为明文处理，即使看起来已经是哈希值，超过 72 字节的密码返回 ErrValidation
+func HashPassword(plain string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plain), PasswordCost)
+	if err != nil {
+		return "", fmt.Errorf("%w: password: %s", cerrors.ErrValidation, err)
+	}
+	return string(hash), nil
+}
+
+// LegacyPasswordHash 返回历史数据中的密码对应的哈希值
+// 已经是哈希值时原样返回，明文密码计算哈希值
+// 只用于迁移数据库中已有的记录，客户端提交的密码使用 HashPassword
+func LegacyPasswordHash(stored string) (string, error) {
+	if IsPasswordHash(stored) {
+		return stored, nil
+	}
+	return HashPassword(stored)
+}
+
+// PasswordHash 已经计算好的密码哈希值
+// 局部更新中的 PasswordHash 会原样保存，只用于迁移等内部流程，例如
+// Patch{Set: bson.M{"password": collections.PasswordHash(hash)}}
+type PasswordHash string
+
+// IsPasswordHash 判断是否是 bcrypt 的哈希值
+func IsPasswordHash(s string) bool {
+	if len(s) != 60 {
+		return false
+	}
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyPassword 校验密码
+// hash 为保存的密码，兼容历史数据中的明文密码
+// rehash 为 true 时表示保存的是明文或者强度不足，校验通过后应当重新计算哈希值
+func VerifyPassword(hash string, plain string) (ok bool, rehash bool) {
+	if hash == "" {
+		return false, false
+	}
+	if !IsPasswordHash(hash) {
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(plain)) == 1, true
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(plain)) != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return true, err != nil || cost < PasswordCost
+}
+
+// WithWriteOnlyFields 声明只写的字段，例如 password
+// 整体更新时这些字段为空表示保持不变，避免前端回传的记录覆盖已有的值
+func WithWriteOnlyFields(fields ...string) Option {
+	return func(c *Config) {
+		c.WriteOnlyFields = append(c.WriteOnlyFields, fields...)
+	}
+}
+
+// WithPasswordFields 声明密码字段，例如 password
+// 写入时计算 bcrypt 哈希值，同时也是只写的字段
+// 整体更新以及 UpdateFields 时与保存的值相同表示保持不变，例如先查询再整体更新的记录
+func WithPasswordFields(fields ...string) Option {
+	return func(c *Config) {
+		c.PasswordFields = append(c.PasswordFields, fields...)
+		c.WriteOnlyFields = append(c.WriteOnlyFields, fields...)
+	}
+}
+
+// hashPasswords 计算 $set 中密码字段的哈希值
+// stored 返回保存的记录，为空时表示新记录或者批量更新，总是计算哈希值
+// 空字符串表示保持不变，PasswordHash 原样保存，其他值都作为明文
+func (r *Repository[T, PT]) hashPasswords(set bson.D, stored func() (bson.Raw, error)) (bson.D, error) {
+	if len(r.conf.PasswordFields) == 0 {
+		return set, nil
+	}
+	hashed := make(bson.D, 0, len(set))
+	for _, e := range set {
+		if !contains(r.conf.PasswordFields, e.Key) {
+			hashed = append(hashed, e)
+			continue
+		}
+		switch v := e.Value.(type) {
+		case PasswordHash:
+			if !IsPasswordHash(string(v)) {
+				return nil, fmt.Errorf("%w: %s is not a password hash", cerrors.ErrValidation, e.Key)
+			}
+			e.Value = string(v)
+		case string:
+			if v == "" {
+				continue
+			}
+			if stored != nil {
+				raw, err := stored()
+				if err != nil {
+					return nil, err
+				}
+				if s, ok := lookupString(raw, e.Key); ok && s == v {
+					continue
+				}
+			}
+			hash, err := HashPassword(v)
+			if err != nil {
+				return nil, err
+			}
+			e.Value = hash
+		case nil:
+			continue
+		default:
+			return nil, fmt.Errorf("%w: %s needs a string", cerrors.ErrValidation, e.Key)
+		}
+		hashed = append(hashed, e)
+	}
+	return hashed, nil
+}
+
+// storedDocument 返回读取保存的记录的函数
+// 只在需要时读取一次，记录不存在时返回空
+func (r *Repository[T, PT]) storedDocument(ctx context.Context, filter bson.D) func() (bson.Raw, error) {
+	var raw bson.Raw
+	loaded := false
+	return func() (bson.Raw, error) {
+		if loaded {
+			return raw, nil
+		}
+		found, err := r.collection().FindOne(ctx, filter)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return nil, err
+		}
+		raw, loaded = found, true
+		return raw, nil
+	}
+}
+
+// lookupString 返回记录中字符串字段的值
+func lookupString(raw bson.Raw, field string) (string, bool) {
+	if len(raw) == 0 {
+		return "", false
+	}
+	rv, err := raw.LookupErr(strings.Split(field, ".")...)
+	if err != nil {
+		return "", false
+	}
+	return rv.StringValueOK()
+}
+
+// omitEmpty 去掉 $set 中为空的字段
+func omitEmpty(set bson.D, fields []string) bson.D {
+	kept := make(bson.D, 0, len(set))
+	for _, e := range set {
+		if contains(fields, e.Key) && (e.Value == nil || e.Value == "") {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	return kept
+}
@@ -0,0 +1,114 @@
+package collections_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/r2day/collections"
+	cerrors "github.com/r2day/collections/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := collections.HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := collections.HashPassword(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == hash {
+		t.Fatal("a hash submitted by a client should be hashed again")
+	}
+	if ok, _ := collections.VerifyPassword(again, hash); !ok {
+		t.Fatal("the submitted hash should be treated as the plain password")
+	}
+	if kept, err := collections.LegacyPasswordHash(hash); err != nil || kept != hash {
+		t.Fatalf("legacy hash should be kept, got %s %v", kept, err)
+	}
+	if ok, rehash := collections.VerifyPassword("plain", "plain"); !ok || !rehash {
+		t.Fatal("legacy plain password should verify and ask for a rehash")
+	}
+}
+
+func TestPasswordFields(t *testing.T) {
+	repo := newRepo(collections.WithPasswordFields("note"))
+	hash, err := collections.HashPassword("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := create(t, repo, merchantA, &item{Name: "a", Note: hash})
+
+	stored := func() string {
+		t.Helper()
+		m, err := repo.GetOne(as(merchantA), merchantA, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m.Note
+	}
+	first := stored()
+	if first == hash || !collections.IsPasswordHash(first) {
+		t.Fatal("a submitted hash should not be stored as is")
+	}
+
+	// 先查询再整体更新时保持不变
+	m, err := repo.GetOne(as(merchantA), merchantA, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Name = "b"
+	if err := repo.Update(as(merchantA), merchantA, id, m); err != nil {
+		t.Fatal(err)
+	}
+	if stored() != first {
+		t.Fatal("unchanged password should be kept")
+	}
+	if err := repo.UpdateFields(as(merchantA), merchantA, id, m, "note"); err != nil {
+		t.Fatal(err)
+	}
+	if stored() != first {
+		t.Fatal("unchanged password should be kept by UpdateFields")
+	}
+
+	if err := repo.Patch(as(merchantA), merchantA, id, collections.Patch{Set: bson.M{"note": "new"}}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := collections.VerifyPassword(stored(), "new"); !ok {
+		t.Fatal("patched password should be hashed")
+	}
+	if _, err := repo.UpdateMany(as(merchantA), merchantA, []string{id}, collections.Patch{Set: bson.M{"note": "bulk"}}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := collections.VerifyPassword(stored(), "bulk"); !ok {
+		t.Fatal("bulk updated password should be hashed")
+	}
+
+	if err := repo.Patch(as(merchantA), merchantA, id, collections.Patch{Set: bson.M{"note": collections.PasswordHash(hash)}}); err != nil {
+		t.Fatal(err)
+	}
+	if stored() != hash {
+		t.Fatal("PasswordHash should be stored as is")
+	}
+	err = repo.Patch(as(merchantA), merchantA, id, collections.Patch{Set: bson.M{"note": collections.PasswordHash("plain")}})
+	if !errors.Is(err, cerrors.ErrValidation) {
+		t.Fatalf("PasswordHash needs a hash, got %v", err)
+	}
+}
+
+func TestWriteOnlyFields(t *testing.T) {
+	repo := newRepo(collections.WithWriteOnlyFields("note"))
+	id := create(t, repo, merchantA, &item{Name: "a", Note: "secret"})
+
+	if err := repo.Update(as(merchantA), merchantA, id, &item{Name: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := repo.GetOne(as(merchantA), merchantA, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "b" || m.Note != "secret" {
+		t.Fatalf("empty write-only field should be kept, got %+v", m)
+	}
+}
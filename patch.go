@@ -0,0 +1,304 @@
+package collections
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	cerrors "github.com/r2day/collections/errors"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// immutableFields 不允许通过更新修改的字段
+var immutableFields = []string{idField, "merchant_id", "created_at", "account_id", "created_by", versionField, deletedAtField, deletedByField}
+
+// isImmutableField 判断是否是不可变字段
+func isImmutableField(key string) bool {
+	for _, f := range immutableFields {
+		if key == f || strings.HasPrefix(key, f+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Patch 局部更新
+// 只修改指定的字段，未指定的字段保持不变
+type Patch struct {
+	// 设置字段，例如 {"user_info.phone": "138..."}
+	Set bson.M
+	// 删除字段
+	Unset []string
+	// 数字字段累加，例如 {"counter": 1}
+	// 金额字段使用 Money，例如 {"assets.balance": MustParseMoney("-10", DefaultCurrency)}
+	// 币种需要与记录中的币种一致，否则返回 ErrValidation
+	Inc bson.M
+	// 向数组字段追加元素
+	Push bson.M
+	// 从数组字段中移除元素
+	Pull bson.M
+}
+
+// fields 返回更新的字段以及对应的操作符
+func (p Patch) fields() map[string]string {
+	fields := make(map[string]string)
+	for key := range p.Set {
+		fields[key] = "$set"
+	}
+	for _, key := range p.Unset {
+		fields[key] = "$unset"
+	}
+	for key := range p.Inc {
+		fields[key] = "$inc"
+	}
+	for key := range p.Push {
+		fields[key] = "$push"
+	}
+	for key := range p.Pull {
+		fields[key] = "$pull"
+	}
+	return fields
+}
+
+// Validate 检查更新内容
+// 不允许使用操作符、修改不可变字段以及对同一个字段执行多个操作
+func (p Patch) Validate() error {
+	fields := p.fields()
+	if len(fields) == 0 {
+		return fmt.Errorf("%w: empty patch", cerrors.ErrValidation)
+	}
+	count := len(p.Set) + len(p.Unset) + len(p.Inc) + len(p.Push) + len(p.Pull)
+	if count != len(fields) {
+		return fmt.Errorf("%w: conflicting operators in patch", cerrors.ErrValidation)
+	}
+	for key := range fields {
+		if key == "" || strings.Contains(key, "$") {
+			return fmt.Errorf("%w: invalid field %s", cerrors.ErrValidation, key)
+		}
+		if isImmutableField(key) || key == "updated_at" {
+			return fmt.Errorf("%w: field %s is immutable", cerrors.ErrValidation, key)
+		}
+	}
+	for key, val := range p.Inc {
+		switch val.(type) {
+		case int, int32, int64, float32, float64, Money:
+		default:
+			return fmt.Errorf("%w: field %s needs a number", cerrors.ErrValidation, key)
+		}
+	}
+	return nil
+}
+
+// update 返回 mongo 的更新语句
+// 同时设定更新时间以及版本号
+func (p Patch) update() bson.D {
+	set := toD(p.Set)
+	set = append(set, bson.E{Key: "updated_at", Value: now()})
+	update := bson.D{{Key: "$set", Value: set}}
+	if len(p.Unset) > 0 {
+		unset := bson.D{}
+		for _, key := range p.Unset {
+			unset = append(unset, bson.E{Key: key, Value: ""})
+		}
+		update = append(update, bson.E{Key: "$unset", Value: unset})
+	}
+	// 更新版本号
+	inc := bson.D{}
+	for _, e := range toD(p.Inc) {
+		// 金额累加最小货币单位
+		if m, ok := e.Value.(Money); ok {
+			e = bson.E{Key: e.Key + "." + moneyUnitsField, Value: m.Units}
+		}
+		inc = append(inc, e)
+	}
+	inc = append(inc, bson.E{Key: versionField, Value: 1})
+	update = append(update, bson.E{Key: "$inc", Value: inc})
+	if len(p.Push) > 0 {
+		update = append(update, bson.E{Key: "$push", Value: toD(p.Push)})
+	}
+	if len(p.Pull) > 0 {
+		update = append(update, bson.E{Key: "$pull", Value: toD(p.Pull)})
+	}
+	return update
+}
+
+// currencyMismatches 返回金额累加时记录中币种不一致的条件
+// 记录中没有币种时视为默认币种
+func (p Patch) currencyMismatches() bson.A {
+	conds := bson.A{}
+	for key, val := range p.Inc {
+		m, ok := val.(Money)
+		if !ok {
+			continue
+		}
+		currencies := bson.A{m.currency()}
+		if m.currency() == DefaultCurrency {
+			currencies = append(currencies, "", nil)
+		}
+		conds = append(conds, bson.D{{Key: key + "." + moneyCurrencyField, Value: bson.D{{Key: "$nin", Value: currencies}}}})
+	}
+	return conds
+}
+
+// checkCurrency 检查金额累加的币种与记录中的币种是否一致
+// 不一致时返回 ErrValidation，否则返回更新时附加的过滤条件
+func (r *Repository[T, PT]) checkCurrency(ctx context.Context, filter bson.D, patch Patch) ([]bson.E, error) {
+	mismatches := patch.currencyMismatches()
+	if len(mismatches) == 0 {
+		return nil, nil
+	}
+	cond := append(append(bson.D{}, filter...), bson.E{Key: "$or", Value: mismatches})
+	count, err := r.collection().CountDocuments(ctx, cond)
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("%w: currency mismatch in patch", cerrors.ErrValidation)
+	}
+	// 避免检查之后币种被修改
+	return []bson.E{{Key: "$nor", Value: mismatches}}, nil
+}
+
+// toD 将 bson.M 转换为 bson.D
+func toD(m bson.M) bson.D {
+	d := make(bson.D, 0, len(m))
+	for key, val := range m {
+		d = append(d, bson.E{Key: key, Value: val})
+	}
+	return d
+}
+
+// setDocument 返回整体更新时的 $set 内容
+// 去掉不可变字段，避免覆盖创建时间等信息
+func setDocument(m interface{}) (bson.D, error) {
+	data, err := bson.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	doc := bson.D{}
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	set := make(bson.D, 0, len(doc))
+	for _, e := range doc {
+		if !isImmutableField(e.Key) {
+			set = append(set, e)
+		}
+	}
+	return set, nil
+}
+
+// omitFields 去掉 $set 中指定的字段
+func omitFields(set bson.D, fields ...string) bson.D {
+	kept := make(bson.D, 0, len(set))
+	for _, e := range set {
+		if !contains(fields, e.Key) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// maskDocument 返回字段掩码对应的更新内容
+// 记录中不存在的字段会被删除
+func maskDocument(m interface{}, fields []string) (Patch, error) {
+	data, err := bson.Marshal(m)
+	if err != nil {
+		return Patch{}, err
+	}
+	raw := bson.Raw(data)
+	patch := Patch{Set: bson.M{}}
+	for _, key := range fields {
+		rv, err := raw.LookupErr(strings.Split(key, ".")...)
+		if err != nil {
+			patch.Unset = append(patch.Unset, key)
+			continue
+		}
+		var v interface{}
+		if err := rv.Unmarshal(&v); err != nil {
+			return Patch{}, err
+		}
+		patch.Set[key] = v
+	}
+	return patch, nil
+}
+
+// Patch 局部更新
+// patch	PATCH http://my.api.url/posts/123
+// 只能更新访问范围内的记录
+func (r *Repository[T, PT]) Patch(ctx context.Context, scope Scope, id string, patch Patch) error {
+	return r.patchOne(ctx, scope, id, patch)
+}
+
+// patchOne 局部更新一条记录
+// conds 为额外的过滤条件，例如版本号
+func (r *Repository[T, PT]) patchOne(ctx context.Context, scope Scope, id string, patch Patch, conds ...bson.E) error {
+	logCtx := log.WithField("id", id).WithField("merchantID", scope.MerchantID)
+	scope, err := actorScope(ctx, scope)
+	if err != nil {
+		logCtx.Error(err)
+		return err
+	}
+	patch, err = r.checkPatch(patch)
+	if err != nil {
+		logCtx.Error(err)
+		return err
+	}
+	if err := r.checkPatchLevels(scope, patch); err != nil {
+		logCtx.Error(err)
+		return err
+	}
+	filter, err := r.filterByID(scope, id)
+	if err != nil {
+		logCtx.Error(err)
+		return err
+	}
+	if patch.Set, err = r.hashPatch(patch.Set, r.storedDocument(ctx, filter)); err != nil {
+		logCtx.Error(err)
+		return err
+	}
+	guards, err := r.checkCurrency(ctx, filter, patch)
+	if err != nil {
+		logCtx.Error(err)
+		return err
+	}
+	conds = append(conds, guards...)
+	result, err := r.collection().UpdateOne(ctx, append(filter, conds...), patch.update())
+	if err != nil {
+		logCtx.Error(err)
+		return r.duplicateError(err)
+	}
+	if result.MatchedCount < 1 {
+		logCtx.Warning("no matched record")
+		return cerrors.NotFound(id)
+	}
+	return nil
+}
+
+// hashPatch 计算局部更新中密码字段的哈希值
+func (r *Repository[T, PT]) hashPatch(set bson.M, stored func() (bson.Raw, error)) (bson.M, error) {
+	if len(set) == 0 || len(r.conf.PasswordFields) == 0 {
+		return set, nil
+	}
+	hashed, err := r.hashPasswords(toD(set), stored)
+	if err != nil {
+		return nil, err
+	}
+	result := make(bson.M, len(hashed))
+	for _, e := range hashed {
+		result[e.Key] = e.Value
+	}
+	return result, nil
+}
+
+// UpdateFields 只更新指定的字段
+// fields 为字段掩码，例如 "user_info.phone"，取值来自 m
+func (r *Repository[T, PT]) UpdateFields(ctx context.Context, scope Scope, id string, m PT, fields ...string) error {
+	patch, err := maskDocument(m, fields)
+	if err != nil {
+		log.WithField("id", id).Error(err)
+		return err
+	}
+	return r.Patch(ctx, scope, id, patch)
+}
@@ -0,0 +1,108 @@
+package collections_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/r2day/collections"
+	cerrors "github.com/r2day/collections/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestPatch(t *testing.T) {
+	repo := newRepo()
+	id := create(t, repo, merchantA, &item{Name: "a", Qty: 1, Tags: []string{"x"}, Note: "n"})
+
+	err := repo.Patch(as(merchantA), merchantA, id, collections.Patch{
+		Set:   bson.M{"name": "b"},
+		Inc:   bson.M{"qty": 2},
+		Push:  bson.M{"tags": "y"},
+		Unset: []string{"note"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := repo.GetOne(as(merchantA), merchantA, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "b" || m.Qty != 3 || !equal(m.Tags, []string{"x", "y"}) || m.Note != "" || m.Version != 2 {
+		t.Fatalf("unexpected patched record %+v", m)
+	}
+
+	for _, patch := range []collections.Patch{
+		{},
+		{Set: bson.M{"merchant_id": "m-b"}},
+		{Set: bson.M{"version": 9}},
+		{Set: bson.M{"name": "c"}, Unset: []string{"name"}},
+	} {
+		if err := repo.Patch(as(merchantA), merchantA, id, patch); !errors.Is(err, cerrors.ErrValidation) {
+			t.Fatalf("%+v: expected validation error, got %v", patch, err)
+		}
+	}
+}
+
+func TestPatchSchema(t *testing.T) {
+	repo := newRepo()
+	ctx := context.Background()
+	id := create(t, repo, merchantA, &item{Name: "a", Price: collections.MustParseMoney("2", "")})
+
+	for _, patch := range []collections.Patch{
+		{Set: bson.M{"bogus_field": 1}},
+		{Set: bson.M{"price": 1.5}},
+		{Set: bson.M{"price.units": "x"}},
+		{Set: bson.M{"qty": "many"}},
+		{Set: bson.M{"qty": 1.5}},
+		{Set: bson.M{"tags": "x"}},
+		{Set: bson.M{"name.first": "x"}},
+		{Unset: []string{"bogus_field"}},
+		{Inc: bson.M{"name": 1}},
+		{Inc: bson.M{"price": 1}},
+		{Inc: bson.M{"qty": collections.MustParseMoney("1", "")}},
+		{Inc: bson.M{"price": collections.MustParseMoney("1", "USD")}},
+		{Push: bson.M{"name": "x"}},
+		{Push: bson.M{"tags": 1}},
+		{Pull: bson.M{"qty": 1}},
+	} {
+		if err := repo.Patch(as(merchantA), merchantA, id, patch); !errors.Is(err, cerrors.ErrValidation) {
+			t.Fatalf("%+v: expected validation error, got %v", patch, err)
+		}
+	}
+
+	// JSON 中的数字转换为字段的类型
+	err := repo.Patch(as(merchantA), merchantA, id, collections.Patch{Set: bson.M{
+		"qty":        float64(3),
+		"tags.0":     "x",
+		"price":      bson.M{"units": 150, "currency": "CNY"},
+		"status":     true,
+		"updated_by": nil,
+	}})
+	expectError(t, err, cerrors.ErrValidation)
+	err = repo.Patch(as(merchantA), merchantA, id, collections.Patch{Set: bson.M{
+		"qty":   float64(3),
+		"tags":  bson.A{"x"},
+		"price": bson.M{"units": 150, "currency": "CNY"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.Patch(as(merchantA), merchantA, id, collections.Patch{Set: bson.M{"tags.0": "y"}, Inc: bson.M{"price": collections.MustParseMoney("0.5", "")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := repo.Store().Collection(repo.CollectionName()).FindOne(ctx, bson.D{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := raw.Lookup("qty").Int64OK(); !ok {
+		t.Fatalf("qty should be stored as an integer, got %s", raw.Lookup("qty").Type)
+	}
+	m, err := repo.GetOne(as(merchantA), merchantA, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Qty != 3 || !equal(m.Tags, []string{"y"}) || m.Price.Units != 200 {
+		t.Fatalf("unexpected patched record %+v", m)
+	}
+}
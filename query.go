@@ -0,0 +1,384 @@
+package collections
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	cerrors "github.com/r2day/collections/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// FieldType 过滤字段的类型
+// 用于将 url 中的字符串转换为数据库中保存的类型
+type FieldType int
+
+const (
+	// StringField 字符串
+	StringField FieldType = iota
+	// IntField 整数
+	IntField
+	// FloatField 浮点数
+	FloatField
+	// BoolField 布尔值，支持 true/false/1/0/是/否
+	BoolField
+	// TimeField 时间
+	TimeField
+	// ObjectIDField 对象id
+	ObjectIDField
+	// MoneyField 金额，按照最小货币单位比较
+	MoneyField
+)
+
+// FilterField 可以过滤的字段
+type FilterField struct {
+	// 字段名称，支持 a.b 的形式
+	Key string
+	// 字段类型
+	Type FieldType
+	// 金额字段的币种，为空时使用默认币种
+	Currency string
+}
+
+// StringFilter 字符串类型的过滤字段
+func StringFilter(key string) FilterField {
+	return FilterField{Key: key, Type: StringField}
+}
+
+// IntFilter 整数类型的过滤字段
+func IntFilter(key string) FilterField {
+	return FilterField{Key: key, Type: IntField}
+}
+
+// FloatFilter 浮点数类型的过滤字段
+func FloatFilter(key string) FilterField {
+	return FilterField{Key: key, Type: FloatField}
+}
+
+// BoolFilter 布尔类型的过滤字段
+func BoolFilter(key string) FilterField {
+	return FilterField{Key: key, Type: BoolField}
+}
+
+// TimeFilter 时间类型的过滤字段
+func TimeFilter(key string) FilterField {
+	return FilterField{Key: key, Type: TimeField}
+}
+
+// ObjectIDFilter 对象id类型的过滤字段
+func ObjectIDFilter(key string) FilterField {
+	return FilterField{Key: key, Type: ObjectIDField}
+}
+
+// MoneyFilter 金额过滤字段
+// 例如 amount_info.total__gte=12.50，currency 为字段的币种，默认为 DefaultCurrency
+// 过滤值中的币种与字段不一致时返回 ErrValidation，例如 amount_info.total__gte=USD 12.50
+func MoneyFilter(key string, currency ...string) FilterField {
+	f := FilterField{Key: key, Type: MoneyField, Currency: DefaultCurrency}
+	if len(currency) > 0 && currency[0] != "" {
+		f.Currency = strings.ToUpper(currency[0])
+	}
+	return f
+}
+
+// path 返回数据库中的字段
+// 金额字段比较最小货币单位
+func (f FilterField) path() string {
+	if f.Type == MoneyField {
+		return f.Key + "." + moneyUnitsField
+	}
+	return f.Key
+}
+
+// defaultFilterFields 所有模型都可以过滤的字段
+var defaultFilterFields = []FilterField{
+	StringFilter("account_id"),
+	StringFilter("created_by"),
+	BoolFilter("status"),
+	TimeFilter("created_at"),
+	TimeFilter("updated_at"),
+}
+
+// filterOpSep 字段与操作符的分隔符
+// 例如 amount__gte=100, name__contains=张
+const filterOpSep = "__"
+
+// 支持的过滤操作符
+const (
+	// 等于，多个值时等同于 in
+	opEq = "eq"
+	// 不等于
+	opNe = "ne"
+	// 大于
+	opGt = "gt"
+	// 大于等于
+	opGte = "gte"
+	// 小于
+	opLt = "lt"
+	// 小于等于
+	opLte = "lte"
+	// 在列表中
+	opIn = "in"
+	// 不在列表中
+	opNin = "nin"
+	// 在范围内（含边界），需要两个值
+	opBetween = "between"
+	// 以指定内容开头
+	opPrefix = "prefix"
+	// 包含指定内容（忽略大小写）
+	opContains = "contains"
+	// 字段是否存在
+	opExists = "exists"
+)
+
+// splitFilterKey 拆分字段名称和操作符
+func splitFilterKey(key string) (string, string) {
+	if i := strings.LastIndex(key, filterOpSep); i > 0 {
+		return key[:i], key[i+len(filterOpSep):]
+	}
+	return key, opEq
+}
+
+// hasFilterOn 判断过滤参数中是否包含指定字段，包括 field__op 形式的条件
+func hasFilterOn(filterMap map[string][]string, field string) bool {
+	for key := range filterMap {
+		if name, _ := splitFilterKey(key); name == field {
+			return true
+		}
+	}
+	return false
+}
+
+// reservedFilterFields 不允许过滤的字段
+// 命名空间和访问级别由 Scope 决定，密码等敏感信息不允许作为查询条件
+var reservedFilterFields = []string{"merchant_id", "access_level", "password"}
+
+// isReservedFilterField 判断是否是保留字段
+func isReservedFilterField(key string) bool {
+	for _, reserved := range reservedFilterFields {
+		if key == reserved || strings.HasPrefix(key, reserved+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupFilterField 返回声明的过滤字段
+func lookupFilterField(fields []FilterField, key string) (FilterField, bool) {
+	for _, f := range fields {
+		if f.Key == key {
+			return f, true
+		}
+	}
+	for _, f := range defaultFilterFields {
+		if f.Key == key {
+			return f, true
+		}
+	}
+	return FilterField{}, false
+}
+
+// parseFilters 将 url 中的过滤参数转换为过滤条件
+// 只允许过滤声明的字段，其他字段以及保留字段返回 ErrValidation
+// 同一个字段的多个条件会合并为一个操作符文档，例如 {trade_time: {$gte: a, $lte: b}}, {status: {$eq: 1, $ne: 2}}
+// 不含时区的时间按照 loc 解析
+func parseFilters(filterMap map[string][]string, fields []FilterField, loc *time.Location) (bson.D, error) {
+	keys := make([]string, 0, len(filterMap))
+	for key := range filterMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	filters := bson.D{}
+	// 各字段的条件，相等条件使用 $eq
+	ops := make(map[string]bson.D)
+	order := make([]string, 0)
+	for _, key := range keys {
+		vals := filterMap[key]
+		name, op := splitFilterKey(key)
+		if isReservedFilterField(name) {
+			return nil, fmt.Errorf("%w: filter %s is reserved", cerrors.ErrValidation, name)
+		}
+		field, ok := lookupFilterField(fields, name)
+		if !ok {
+			return nil, fmt.Errorf("%w: filter %s is not allowed", cerrors.ErrValidation, name)
+		}
+
+		cond, err := field.condition(op, vals, loc)
+		if err != nil {
+			return nil, err
+		}
+		path := field.path()
+		if _, ok := ops[path]; !ok {
+			order = append(order, path)
+		}
+		for _, e := range cond {
+			if e.Key == "" {
+				e.Key = "$eq"
+			}
+			// 同一个操作符只能出现一次，否则 mongo 只保留最后一个
+			if hasKey(ops[path], e.Key) {
+				return nil, field.invalid(op, "duplicate operator "+e.Key)
+			}
+			ops[path] = append(ops[path], e)
+		}
+	}
+	for _, key := range order {
+		// 只有相等条件时直接使用值
+		if len(ops[key]) == 1 && ops[key][0].Key == "$eq" {
+			filters = append(filters, bson.E{Key: key, Value: ops[key][0].Value})
+			continue
+		}
+		filters = append(filters, bson.E{Key: key, Value: ops[key]})
+	}
+	return filters, nil
+}
+
+// hasKey 判断文档中是否包含指定的键
+func hasKey(doc bson.D, key string) bool {
+	for _, e := range doc {
+		if e.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// condition 返回字段的过滤条件
+// 相等条件返回 Key 为空的元素
+func (f FilterField) condition(op string, vals []string, loc *time.Location) (bson.D, error) {
+	if len(vals) == 0 {
+		return nil, f.invalid(op, "missing value")
+	}
+
+	switch op {
+	case opEq:
+		if len(vals) > 1 {
+			return f.condition(opIn, vals, loc)
+		}
+		v, err := f.coerce(vals[0], loc)
+		if err != nil {
+			return nil, err
+		}
+		return bson.D{{Key: "", Value: v}}, nil
+	case opNe, opGt, opGte, opLt, opLte:
+		if len(vals) != 1 {
+			return nil, f.invalid(op, "needs one value")
+		}
+		v, err := f.coerce(vals[0], loc)
+		if err != nil {
+			return nil, err
+		}
+		return bson.D{{Key: "$" + op, Value: v}}, nil
+	case opIn, opNin:
+		values := make(bson.A, 0, len(vals))
+		for _, val := range vals {
+			v, err := f.coerce(val, loc)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return bson.D{{Key: "$" + op, Value: values}}, nil
+	case opBetween:
+		if len(vals) != 2 {
+			return nil, f.invalid(op, "needs two values")
+		}
+		from, err := f.coerce(vals[0], loc)
+		if err != nil {
+			return nil, err
+		}
+		to, err := f.coerce(vals[1], loc)
+		if err != nil {
+			return nil, err
+		}
+		return bson.D{{Key: "$gte", Value: from}, {Key: "$lte", Value: to}}, nil
+	case opPrefix, opContains:
+		if f.Type != StringField {
+			return nil, f.invalid(op, "only supported by string fields")
+		}
+		if len(vals) != 1 {
+			return nil, f.invalid(op, "needs one value")
+		}
+		if op == opPrefix {
+			return bson.D{{Key: "$regex", Value: "^" + regexp.QuoteMeta(vals[0])}}, nil
+		}
+		return bson.D{{Key: "$regex", Value: regexp.QuoteMeta(vals[0])}, {Key: "$options", Value: "i"}}, nil
+	case opExists:
+		exists, err := parseBool(vals[0])
+		if err != nil {
+			return nil, f.invalid(op, err.Error())
+		}
+		return bson.D{{Key: "$exists", Value: exists}}, nil
+	}
+	return nil, f.invalid(op, "unsupported operator")
+}
+
+// coerce 将字符串转换为字段的类型
+func (f FilterField) coerce(val string, loc *time.Location) (interface{}, error) {
+	switch f.Type {
+	case IntField:
+		v, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64)
+		if err != nil {
+			return nil, f.invalid("", "invalid integer "+val)
+		}
+		return v, nil
+	case FloatField:
+		v, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if err != nil {
+			return nil, f.invalid("", "invalid number "+val)
+		}
+		return v, nil
+	case BoolField:
+		v, err := parseBool(val)
+		if err != nil {
+			return nil, f.invalid("", err.Error())
+		}
+		return v, nil
+	case TimeField:
+		v, err := ParseTime(val, loc)
+		if err != nil {
+			return nil, f.invalid("", err.Error())
+		}
+		return v, nil
+	case ObjectIDField:
+		v, err := ObjectIDFromHex(val)
+		if err != nil {
+			return nil, f.invalid("", err.Error())
+		}
+		return v, nil
+	case MoneyField:
+		v, err := ParseMoney(val, Money{Currency: f.Currency}.currency())
+		if err != nil {
+			return nil, f.invalid("", err.Error())
+		}
+		return v.Units, nil
+	}
+	return val, nil
+}
+
+// invalid 返回字段校验失败的错误
+func (f FilterField) invalid(op string, reason string) error {
+	if op == "" {
+		return fmt.Errorf("%w: filter %s: %s", cerrors.ErrValidation, f.Key, reason)
+	}
+	return fmt.Errorf("%w: filter %s%s%s: %s", cerrors.ErrValidation, f.Key, filterOpSep, op, reason)
+}
+
+// parseBool 解析布尔值
+func parseBool(val string) (bool, error) {
+	switch strings.TrimSpace(val) {
+	case "是":
+		return true, nil
+	case "否":
+		return false, nil
+	}
+	v, err := strconv.ParseBool(strings.TrimSpace(val))
+	if err != nil {
+		return false, fmt.Errorf("invalid bool %s", val)
+	}
+	return v, nil
+}
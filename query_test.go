@@ -0,0 +1,78 @@
+package collections_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/r2day/collections"
+	cerrors "github.com/r2day/collections/errors"
+)
+
+func TestFilterDSL(t *testing.T) {
+	repo := newRepo()
+	create(t, repo, merchantA, &item{Name: "apple", Qty: 1, Tags: []string{"fruit"}, Price: collections.MustParseMoney("1.50", "")})
+	create(t, repo, merchantA, &item{Name: "banana", Qty: 5, Tags: []string{"fruit"}, Price: collections.MustParseMoney("3", "")})
+	create(t, repo, merchantA, &item{Name: "carrot", Qty: 10, Tags: []string{"vegetable"}, Price: collections.MustParseMoney("0.80", "")})
+
+	cases := []struct {
+		filter map[string][]string
+		want   []string
+	}{
+		{map[string][]string{"qty__gte": {"5"}}, []string{"banana", "carrot"}},
+		{map[string][]string{"qty__between": {"2", "9"}}, []string{"banana"}},
+		{map[string][]string{"qty": {"1", "10"}}, []string{"apple", "carrot"}},
+		{map[string][]string{"name__prefix": {"ba"}}, []string{"banana"}},
+		{map[string][]string{"name__contains": {"RR"}}, []string{"carrot"}},
+		{map[string][]string{"tags": {"fruit"}, "qty__lt": {"5"}}, []string{"apple"}},
+		{map[string][]string{"price__gt": {"1"}}, []string{"apple", "banana"}},
+		{map[string][]string{"price__gt": {"CNY 1"}}, []string{"apple", "banana"}},
+		{map[string][]string{"name__nin": {"apple", "banana"}}, []string{"carrot"}},
+		{map[string][]string{"qty": {"5"}, "qty__ne": {"1"}}, []string{"banana"}},
+		{map[string][]string{"qty": {"5"}, "qty__ne": {"5"}}, []string{}},
+		{map[string][]string{"qty__gte": {"1"}, "qty__lt": {"10"}, "name__ne": {"apple"}}, []string{"banana"}},
+	}
+	for _, c := range cases {
+		list, _, err := repo.GetList(as(merchantA), merchantA, params(c.filter))
+		if err != nil {
+			t.Fatalf("%v: %v", c.filter, err)
+		}
+		if !equal(names(list), c.want) {
+			t.Fatalf("%v: expected %v, got %v", c.filter, c.want, names(list))
+		}
+	}
+
+	for _, filter := range []map[string][]string{
+		{"unknown": {"1"}},
+		{"merchant_id": {"m-b"}},
+		{"qty": {"many"}},
+		{"qty__regex": {"1"}},
+		{"qty__contains": {"1"}},
+		{"qty": {"1"}, "qty__eq": {"5"}},
+		{"qty__gte": {"1"}, "qty__between": {"2", "9"}},
+		{"price__gt": {"USD 1"}},
+	} {
+		if _, _, err := repo.GetList(as(merchantA), merchantA, params(filter)); !errors.Is(err, cerrors.ErrValidation) {
+			t.Fatalf("%v: expected validation error, got %v", filter, err)
+		}
+	}
+
+	// 通过操作符过滤状态时不再添加默认的状态过滤器
+	enabled := &item{Name: "durian"}
+	enabled.Status = true
+	create(t, repo, merchantA, enabled)
+	for filter, want := range map[string][]string{
+		"status__ne": {"true"},
+		"status__in": {"false"},
+	} {
+		urlParams := params(map[string][]string{filter: want})
+		urlParams.HasFilter = true
+		urlParams.FilterCommon.Status = true
+		list, _, err := repo.GetList(as(merchantA), merchantA, urlParams)
+		if err != nil {
+			t.Fatalf("%s: %v", filter, err)
+		}
+		if !equal(names(list), []string{"apple", "banana", "carrot"}) {
+			t.Fatalf("%s: expected disabled records, got %v", filter, names(list))
+		}
+	}
+}
@@ -0,0 +1,634 @@
+package collections
+
+import (
+	"context"
+	"time"
+
+	cerrors "github.com/r2day/collections/errors"
+	"github.com/r2day/rest"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// FilterHook 额外的过滤器
+// 在 urlParams.HasFilter 时调用，返回需要追加的过滤条件
+type FilterHook func(urlParams *rest.UrlParams) bson.D
+
+// Config 仓库配置
+type Config struct {
+	// 表名称
+	CollectionName string
+	// 资源名称
+	// 用于识别 ReferenceArrayInput 和 ReferenceManyField 请求
+	ResourceName string
+	// 额外的过滤器
+	FilterHooks []FilterHook
+	// 存储后端
+	// 为空时使用 DefaultStore
+	Store Store
+	// 可以排序的字段
+	// _id, created_at, updated_at, status 默认可以排序
+	SortableFields []string
+	// 可以过滤的字段以及类型
+	// account_id, created_by, status, created_at, updated_at 默认可以过滤
+	// 未声明的字段以及 merchant_id, access_level, password 不允许过滤
+	FilterFields []FilterField
+	// 以 Money 保存的金额字段
+	// 用于迁移历史数据，MoneyFilter 声明的字段会自动加入
+	MoneyFields []string
+	// 校验规则
+	Rules []Rule
+	// 是否将校验规则安装为 $jsonSchema
+	JSONSchema bool
+	// 只写的字段，整体更新时为空表示保持不变
+	WriteOnlyFields []string
+	// 密码字段，写入时计算哈希值
+	PasswordFields []string
+	// 索引
+	// merchant_id + access_level 以及 merchant_id + created_at 默认创建
+	Indexes []Index
+	// 值为访问级别的字段，access_level 默认包含在内
+	LevelFields []string
+	// 是否开启软删除
+	SoftDelete bool
+	// 已删除记录的保留时间，0 表示永久保留
+	Retention time.Duration
+}
+
+// Option 仓库配置项
+type Option func(*Config)
+
+// WithFilterHooks 添加额外的过滤器
+func WithFilterHooks(hooks ...FilterHook) Option {
+	return func(c *Config) {
+		c.FilterHooks = append(c.FilterHooks, hooks...)
+	}
+}
+
+// WithDatabase 指定数据库
+// 可用于多数据库、按商户分库以及测试
+func WithDatabase(database *mongo.Database) Option {
+	return WithStore(NewMongoStore(database))
+}
+
+// WithStore 指定存储后端
+// 例如使用内存实现进行测试
+func WithStore(store Store) Option {
+	return func(c *Config) {
+		c.Store = store
+	}
+}
+
+// WithSortableFields 声明可以排序的字段
+// 一般应该是建立了索引的字段
+func WithSortableFields(fields ...string) Option {
+	return func(c *Config) {
+		c.SortableFields = append(c.SortableFields, fields...)
+	}
+}
+
+// WithFilterFields 声明可以过滤的字段
+// 过滤参数会按照字段类型进行转换，并且支持 字段__操作符 的形式
+func WithFilterFields(fields ...FilterField) Option {
+	return func(c *Config) {
+		c.FilterFields = append(c.FilterFields, fields...)
+	}
+}
+
+// WithMoneyFields 声明以 Money 保存的金额字段
+// 例如 assets.balance，MigrateMoney 会转换这些字段的历史数据
+func WithMoneyFields(fields ...string) Option {
+	return func(c *Config) {
+		c.MoneyFields = append(c.MoneyFields, fields...)
+	}
+}
+
+// ListOptions 列表查询选项
+type ListOptions struct {
+	// 排序规则
+	// 为空时使用 url 参数中的排序规则
+	Sort SortSpec
+	// 不统计总数
+	// 数据量较大时统计总数会比较慢
+	WithoutTotal bool
+	// 已删除记录的查询方式
+	// 只在开启软删除时有效
+	Deleted deletedMode
+}
+
+// ListOption 列表查询选项
+type ListOption func(*ListOptions)
+
+// SortBy 指定排序规则，支持多个字段
+func SortBy(spec SortSpec) ListOption {
+	return func(o *ListOptions) {
+		o.Sort = spec
+	}
+}
+
+// WithoutTotal 不统计总数，返回的总数为 -1
+func WithoutTotal() ListOption {
+	return func(o *ListOptions) {
+		o.WithoutTotal = true
+	}
+}
+
+// Repository 通用的数据仓库
+// 根据 react-admin 的规则实现基本的数据操作方法
+// 各模型只需要声明表名称、资源名称以及额外的过滤器
+type Repository[T any, PT Document[T]] struct {
+	conf Config
+}
+
+// NewRepository 创建数据仓库
+func NewRepository[T any, PT Document[T]](collectionName string, resourceName string, opts ...Option) *Repository[T, PT] {
+	conf := Config{
+		CollectionName: collectionName,
+		ResourceName:   resourceName,
+	}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	r := &Repository[T, PT]{conf: conf}
+	register(r)
+	return r
+}
+
+// CollectionName 返回表名称
+func (r *Repository[T, PT]) CollectionName() string {
+	return r.conf.CollectionName
+}
+
+// ResourceName 返回资源名称
+func (r *Repository[T, PT]) ResourceName() string {
+	return r.conf.ResourceName
+}
+
+// Using 返回使用指定数据库的数据仓库
+// 其他配置保持不变
+// database 为空时使用默认的存储后端
+func (r *Repository[T, PT]) Using(database *mongo.Database) *Repository[T, PT] {
+	if database == nil {
+		return r.UsingStore(nil)
+	}
+	return r.UsingStore(NewMongoStore(database))
+}
+
+// UsingStore 返回使用指定存储后端的数据仓库
+// 其他配置保持不变
+func (r *Repository[T, PT]) UsingStore(store Store) *Repository[T, PT] {
+	conf := r.conf
+	conf.Store = store
+	return &Repository[T, PT]{conf: conf}
+}
+
+// Store 返回数据仓库使用的存储后端
+func (r *Repository[T, PT]) Store() Store {
+	if r.conf.Store != nil {
+		return r.conf.Store
+	}
+	return DefaultStore()
+}
+
+// collection 返回表
+// 写入成功后调用 OnChange 注册的变化通知
+func (r *Repository[T, PT]) collection() Collection {
+	store := r.Store()
+	return notifyingCollection{Collection: store.Collection(r.conf.CollectionName), store: store, name: r.conf.CollectionName}
+}
+
+// resolveSort 检查并返回数据库中的排序规则
+// 不允许对未声明的字段进行排序
+func (r *Repository[T, PT]) resolveSort(spec SortSpec) (SortSpec, error) {
+	return resolveSort(spec, r.conf.SortableFields, r.conf.FilterFields)
+}
+
+// decode 将查询结果解析为模型
+func (r *Repository[T, PT]) decode(raw bson.Raw) (PT, error) {
+	result := PT(new(T))
+	if err := bson.Unmarshal(raw, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// decodeAll 将查询结果解析为模型列表
+func (r *Repository[T, PT]) decodeAll(raws []bson.Raw) ([]PT, error) {
+	results := make([]PT, 0, len(raws))
+	for _, raw := range raws {
+		result, err := r.decode(raw)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Create 创建
+// create	POST http://my.api.url/posts
+// 需要 context 中有操作者的访问范围，否则返回 errors.ErrForbiddenLevel，操作者没有商户号时返回 errors.ErrForbiddenTenant
+func (r *Repository[T, PT]) Create(ctx context.Context, m PT) (string, error) {
+	coll := r.collection()
+	base := m.GetBaseModel()
+
+	// 保存时间设定
+	base.CreatedAt = now()
+	// 更新时间设定
+	base.UpdatedAt = base.CreatedAt
+	// 初始版本
+	base.Version = 1
+	// 新记录不能是已删除的状态
+	base.DeletedAt, base.DeletedBy = nil, ""
+	// 商户号以及访问级别与操作者一致
+	// 没有操作者的访问范围或者操作者没有商户号时不能信任客户端传入的值
+	scope, err := actorOf(ctx)
+	if err != nil {
+		log.WithField("m", m).Error(err)
+		return "", err
+	}
+	base.MerchantID = scope.MerchantID
+	base.CreatedBy = scope.AccountID
+	base.AccessLevel = scope.AccessLevel
+	if err := r.checkLevels(scope, m); err != nil {
+		log.WithField("m", m).Error(err)
+		return "", err
+	}
+
+	if err := r.Validate(m); err != nil {
+		log.WithField("m", m).Error(err)
+		return "", err
+	}
+
+	doc, err := r.insertDocument(m)
+	if err != nil {
+		log.WithField("m", m).Error(err)
+		return "", err
+	}
+
+	// 插入记录
+	result, err := coll.InsertOne(ctx, doc)
+	if result == nil {
+		log.WithField("m", m).Error(err)
+		return "", r.duplicateError(err)
+	}
+	objID := result.InsertedID.(primitive.ObjectID)
+	base.ID = objID
+	if err != nil {
+		// 记录已经写入，变化通知返回了错误
+		log.WithField("m", m).Error(err)
+		return objID.Hex(), err
+	}
+	return objID.Hex(), nil
+}
+
+// insertDocument 返回插入的内容
+// 有密码字段时保存哈希值
+func (r *Repository[T, PT]) insertDocument(m PT) (interface{}, error) {
+	if len(r.conf.PasswordFields) == 0 {
+		return m, nil
+	}
+	data, err := bson.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	doc := bson.D{}
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return r.hashPasswords(doc, nil)
+}
+
+// Delete 删除
+// delete	DELETE http://my.api.url/posts/123
+// 只能删除访问范围内的记录，开启软删除时只标记删除
+func (r *Repository[T, PT]) Delete(ctx context.Context, scope Scope, id string) error {
+	logCtx := log.WithField("id", id).WithField("merchantID", scope.MerchantID)
+	scope, err := actorScope(ctx, scope)
+	if err != nil {
+		logCtx.Error(err)
+		return err
+	}
+	filter, err := r.filterByID(scope, id)
+	if err != nil {
+		logCtx.Error(err)
+		return err
+	}
+	// 执行删除
+	deleted, err := r.deleteOne(ctx, scope, filter)
+	if err != nil {
+		logCtx.Error(err)
+		return err
+	}
+
+	if deleted < 1 {
+		logCtx.Warning("deleted < 1")
+		return cerrors.NotFound(id)
+	}
+	return nil
+}
+
+// GetOne 详情
+// getOne	GET http://my.api.url/posts/123
+// 只能获取访问范围内的记录，默认不返回已删除的记录，可以通过 IncludeDeleted 或者 OnlyDeleted 查看
+func (r *Repository[T, PT]) GetOne(ctx context.Context, scope Scope, id string, opts ...ListOption) (PT, error) {
+	scope, err := actorScope(ctx, scope)
+	if err != nil {
+		log.WithField("id", id).Error(err)
+		return nil, err
+	}
+	filter, err := scope.filterByID(id)
+	if err != nil {
+		log.WithField("id", id).Error(err)
+		return nil, err
+	}
+	listOpts := ListOptions{}
+	for _, opt := range opts {
+		opt(&listOpts)
+	}
+	return r.FindOne(ctx, append(filter, r.deletedFilter(listOpts.Deleted)...))
+}
+
+// FindOne 通过过滤条件查找一条记录
+func (r *Repository[T, PT]) FindOne(ctx context.Context, filter bson.D) (PT, error) {
+	coll := r.collection()
+	logCtx := log.WithField("filter", filter)
+
+	raw, err := coll.FindOne(ctx, filter)
+	if err == mongo.ErrNoDocuments {
+		logCtx.Warning("no matched record")
+		return nil, cerrors.ErrNotFound
+	}
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+	return r.decode(raw)
+}
+
+// Find 通过过滤条件查找记录
+// 不限制访问范围，开启软删除时不返回已删除的记录
+func (r *Repository[T, PT]) Find(ctx context.Context, filter bson.D) ([]PT, error) {
+	coll := r.collection()
+	logCtx := log.WithField("filter", filter)
+
+	filter = append(append(bson.D{}, filter...), r.deletedFilter(deletedExclude)...)
+	raws, err := coll.Find(ctx, filter, nil)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+	results, err := r.decodeAll(raws)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetMany 获取条件查询的结果
+// getMany	GET http://my.api.url/posts?filter={"ids":[123,456,789]}
+// 只返回访问范围内的记录
+func (r *Repository[T, PT]) GetMany(ctx context.Context, scope Scope, ids []string) ([]PT, error) {
+	coll := r.collection()
+	logCtx := log.WithField("merchantID", scope.MerchantID).WithField("ids", ids)
+	scope, err := actorScope(ctx, scope)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+	filter, err := r.filterByIDs(scope, ids)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+	raws, err := coll.Find(ctx, filter, nil)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+
+	results, err := r.decodeAll(raws)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetManyReference 获取引用了指定记录的列表
+// getManyReference	GET http://my.api.url/posts?filter={"author_id":345}
+// target 为外键字段，需要通过 WithFilterFields 声明
+func (r *Repository[T, PT]) GetManyReference(ctx context.Context, scope Scope, target string, id string, urlParams *rest.UrlParams, opts ...ListOption) ([]PT, int64, error) {
+	params := *urlParams
+	params.FilterMap = make(map[string][]string, len(urlParams.FilterMap)+1)
+	for key, val := range urlParams.FilterMap {
+		params.FilterMap[key] = val
+	}
+	params.FilterMap[target] = []string{id}
+	return r.GetList(ctx, scope, &params, opts...)
+}
+
+// Update 整体更新
+// update	PUT http://my.api.url/posts/123
+// 只能更新访问范围内的记录，并且记录不能被移动到其他商户下
+// 除不可变字段以及访问级别以外的所有字段都会被替换，m 中未设置的字段会被置为零值（bson 标签带 omitempty 的空字段保持不变）；
+// 只更新部分字段时使用 Patch 或者 UpdateFields
+func (r *Repository[T, PT]) Update(ctx context.Context, scope Scope, id string, m PT) error {
+	scope, err := actorScope(ctx, scope)
+	if err != nil {
+		log.WithField("id", id).Error(err)
+		return err
+	}
+	filter, err := r.filterByID(scope, id)
+	if err != nil {
+		log.WithField("id", id).Error(err)
+		return err
+	}
+	if err := r.checkLevels(scope, m); err != nil {
+		log.WithField("id", id).Error(err)
+		return err
+	}
+	m.GetBaseModel().MerchantID = scope.MerchantID
+	return r.updateOne(ctx, filter, m)
+}
+
+// UpdateByID 通过id更新数据库
+// 直接使用mongo的id进行更新
+// 这种情况一般用于先通过其他字段，例如phone 查找到记录
+// 通过读取记录中的_id 进行更新
+// 与 Update 相同，整体替换记录并且只能更新 context 中操作者访问范围内的记录
+func (r *Repository[T, PT]) UpdateByID(ctx context.Context, objID primitive.ObjectID, m PT) error {
+	scope, err := actorOf(ctx)
+	if err != nil {
+		log.WithField("id", objID.Hex()).Error(err)
+		return err
+	}
+	return r.Update(ctx, scope, objID.Hex(), m)
+}
+
+// updateOne 更新一条记录
+// 整体替换除不可变字段以及访问级别以外的所有字段，只更新部分字段时使用 Patch
+func (r *Repository[T, PT]) updateOne(ctx context.Context, filter bson.D, m PT) error {
+	coll := r.collection()
+	logCtx := log.WithField("filter", filter)
+	// 设定更新时间
+	m.GetBaseModel().UpdatedAt = now()
+
+	if err := r.Validate(m); err != nil {
+		logCtx.Error(err)
+		return err
+	}
+	set, err := setDocument(m)
+	if err != nil {
+		logCtx.Error(err)
+		return err
+	}
+	set = omitEmpty(set, r.conf.WriteOnlyFields)
+	// 访问级别只能通过 Patch 修改，客户端未传时不会被置为 0
+	set = omitFields(set, accessLevelField)
+	if set, err = r.hashPasswords(set, r.storedDocument(ctx, filter)); err != nil {
+		logCtx.Error(err)
+		return err
+	}
+	update := bson.D{{Key: "$set", Value: set}, {Key: "$inc", Value: bson.D{{Key: versionField, Value: 1}}}}
+	result, err := coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logCtx.Error(err)
+		return r.duplicateError(err)
+	}
+
+	if result.MatchedCount < 1 {
+		logCtx.Warning("no matched record")
+		return cerrors.ErrNotFound
+	}
+	return nil
+}
+
+// GetList 获取列表
+// getList	GET http://my.api.url/posts?sort=["title","ASC"]&range=[0, 24]&filter={"title":"bar"}
+// 访问级别以 context 中操作者的级别为准，没有操作者的访问范围时返回 errors.ErrForbiddenLevel
+func (r *Repository[T, PT]) GetList(ctx context.Context, scope Scope, urlParams *rest.UrlParams, opts ...ListOption) ([]PT, int64, error) {
+	coll := r.collection()
+	listOpts := r.listOptions(urlParams, opts)
+	// 声明日志基本信息
+	logCtx := log.WithField("merchantID", scope.MerchantID).WithField("urlParams.FilterMap", urlParams.FilterMap)
+	// 以商户id为基本命名空间
+	// 并且只能看到小于等于自己的级别的数据
+	scope, err := actorScope(ctx, scope)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, 0, err
+	}
+	// 排序方式
+	sort, err := r.resolveSort(listOpts.Sort)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, 0, err
+	}
+
+	filters, err := r.listFilter(scope, urlParams, listOpts)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, 0, err
+	}
+
+	logCtx.WithField("filters", filters).Debug("final filters has been combine")
+	// 获取总数（含过滤规则）
+	totalCounter, err := r.count(ctx, filters, listOpts)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, 0, err
+	}
+
+	// 进行必要分页处理
+	opt := &FindOptions{
+		Sort:  sort.BSON(),
+		Skip:  int64(urlParams.Range.Offset),
+		Limit: int64(urlParams.Range.Limit),
+	}
+
+	// 获取数据列表
+	raws, err := coll.Find(ctx, filters, opt)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, totalCounter, err
+	}
+
+	results, err := r.decodeAll(raws)
+	if err != nil {
+		logCtx.Error(err)
+		return nil, totalCounter, err
+	}
+	return results, totalCounter, nil
+}
+
+// listOptions 返回列表查询选项
+func (r *Repository[T, PT]) listOptions(urlParams *rest.UrlParams, opts []ListOption) ListOptions {
+	listOpts := ListOptions{Sort: SortFromParams(urlParams)}
+	for _, opt := range opts {
+		opt(&listOpts)
+	}
+	return listOpts
+}
+
+// listFilter 根据访问范围以及 url 参数组合过滤条件
+// 过滤条件中包含资源名称或者 id 时为引用查询
+// 一般对应于 ReferenceArrayInput 和 ReferenceManyField，同样只能查询访问范围内的记录
+func (r *Repository[T, PT]) listFilter(scope Scope, urlParams *rest.UrlParams, listOpts ListOptions) (bson.D, error) {
+	// 声明数据库过滤器
+	// 定义基本过滤规则
+	filters := append(scope.Filter(), r.deletedFilter(listOpts.Deleted)...)
+	// 判断是否是通过id查询
+	refIDs := make([]string, 0)
+	isReference := false
+	filterMap := make(map[string][]string, len(urlParams.FilterMap))
+	for key, val := range urlParams.FilterMap {
+		if r.conf.ResourceName == key || key == "id" {
+			refIDs = append(refIDs, val...)
+			isReference = true
+			continue
+		}
+		filterMap[key] = val
+	}
+	if isReference {
+		objIDs, err := ObjectIDsFromHex(refIDs)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, bson.E{Key: idField, Value: bson.D{{Key: "$in", Value: objIDs}}})
+	}
+
+	// 添加更多过滤器
+	// 根据用户规则进行筛选
+	conds, err := parseFilters(filterMap, r.conf.FilterFields, MerchantLocation(scope.MerchantID))
+	if err != nil {
+		return nil, err
+	}
+	filters = append(filters, conds...)
+
+	// 添加状态过滤器
+	// 引用查询只根据id进行查询
+	if urlParams.HasFilter && !isReference {
+		// 已经通过过滤参数指定状态时不再重复添加
+		if !hasFilterOn(filterMap, "status") {
+			filters = append(filters, bson.E{Key: "status", Value: urlParams.FilterCommon.Status})
+		}
+		// 添加模型声明的过滤器
+		for _, hook := range r.conf.FilterHooks {
+			filters = append(filters, hook(urlParams)...)
+		}
+	}
+	return filters, nil
+}
+
+// count 获取总数
+// 指定 WithoutTotal 时不统计，返回 -1
+func (r *Repository[T, PT]) count(ctx context.Context, filters bson.D, listOpts ListOptions) (int64, error) {
+	if listOpts.WithoutTotal {
+		return -1, nil
+	}
+	return r.collection().CountDocuments(ctx, filters)
+}
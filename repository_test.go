@@ -0,0 +1,187 @@
+package collections_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/r2day/collections"
+	cerrors "github.com/r2day/collections/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestTenantScope(t *testing.T) {
+	repo := newRepo()
+	idA := create(t, repo, merchantA, &item{Name: "a"})
+	create(t, repo, merchantB, &item{Name: "b"})
+
+	if _, err := repo.GetOne(as(merchantB), merchantB, idA); !errors.Is(err, cerrors.ErrNotFound) {
+		t.Fatalf("other merchant should not see the record, got %v", err)
+	}
+	if err := repo.Update(as(merchantB), merchantB, idA, &item{Name: "x"}); !errors.Is(err, cerrors.ErrNotFound) {
+		t.Fatalf("other merchant should not update the record, got %v", err)
+	}
+	if err := repo.Delete(as(merchantB), merchantB, idA); !errors.Is(err, cerrors.ErrNotFound) {
+		t.Fatalf("other merchant should not delete the record, got %v", err)
+	}
+	list, total, err := repo.GetList(as(merchantA), merchantA, params(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || !equal(names(list), []string{"a"}) {
+		t.Fatalf("unexpected list %v (%d)", names(list), total)
+	}
+	if _, _, err := repo.GetList(as(merchantA), collections.Scope{}, params(nil)); !errors.Is(err, cerrors.ErrForbiddenTenant) {
+		t.Fatalf("empty merchant should be rejected, got %v", err)
+	}
+	if _, _, err := repo.GetList(as(merchantB), merchantA, params(nil)); !errors.Is(err, cerrors.ErrForbiddenTenant) {
+		t.Fatalf("the operator's merchant should be used, got %v", err)
+	}
+	// 操作者没有商户号时不能使用客户端传入的商户号
+	forged := &item{Name: "forged", BaseModel: collections.BaseModel{MerchantID: merchantB.MerchantID}}
+	_, err = repo.Create(as(collections.Scope{AccessLevel: collections.LevelAdmin}), forged)
+	expectError(t, err, cerrors.ErrForbiddenTenant)
+}
+
+func TestAccessLevel(t *testing.T) {
+	repo := newRepo()
+	ctx := context.Background()
+	staff := merchantA
+	staff.AccessLevel = collections.LevelStaff
+	manager := merchantA
+	manager.AccessLevel = collections.LevelManager
+
+	create(t, repo, staff, &item{Name: "staff"})
+	idManager := create(t, repo, manager, &item{Name: "manager"})
+
+	list, _, err := repo.GetList(as(staff), staff, params(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(names(list), []string{"staff"}) {
+		t.Fatalf("staff should only see own level, got %v", names(list))
+	}
+	if _, err := repo.GetOne(as(staff), staff, idManager); !errors.Is(err, cerrors.ErrNotFound) {
+		t.Fatalf("staff should not read a manager record, got %v", err)
+	}
+	// 列表的访问级别以 context 中操作者的级别为准
+	list, _, err = repo.GetList(as(staff), manager, params(nil))
+	if err != nil || !equal(names(list), []string{"staff"}) {
+		t.Fatalf("the operator's level should be used, got %v %v", names(list), err)
+	}
+	_, _, err = repo.GetList(ctx, manager, params(nil))
+	expectError(t, err, cerrors.ErrForbiddenLevel)
+	_, err = repo.GetPage(ctx, manager, params(nil), "")
+	expectError(t, err, cerrors.ErrForbiddenLevel)
+	_, err = repo.GetOne(ctx, manager, idManager)
+	expectError(t, err, cerrors.ErrForbiddenLevel)
+	err = repo.Patch(ctx, manager, idManager, collections.Patch{Set: bson.M{"name": "x"}})
+	expectError(t, err, cerrors.ErrForbiddenLevel)
+	// 传入的访问级别不会被信任
+	_, err = repo.GetOne(as(staff), manager, idManager)
+	expectError(t, err, cerrors.ErrNotFound)
+	err = repo.Update(as(staff), manager, idManager, &item{Name: "x"})
+	expectError(t, err, cerrors.ErrNotFound)
+	err = repo.Delete(as(staff), merchantA, idManager)
+	expectError(t, err, cerrors.ErrNotFound)
+	_, err = repo.GetOne(as(merchantB), merchantA, idManager)
+	expectError(t, err, cerrors.ErrForbiddenTenant)
+	_, err = repo.Create(ctx, &item{Name: "forged", BaseModel: collections.BaseModel{AccessLevel: collections.LevelAdmin}})
+	expectError(t, err, cerrors.ErrForbiddenLevel)
+
+	err = repo.Patch(as(staff), staff, idManager, collections.Patch{Set: bson.M{"name": "x"}})
+	expectError(t, err, cerrors.ErrNotFound)
+	err = repo.Patch(as(manager), manager, idManager, collections.Patch{Set: bson.M{"access_level": collections.LevelAdmin}})
+	expectError(t, err, cerrors.ErrForbiddenLevel)
+	err = repo.Patch(as(manager), manager, idManager, collections.Patch{Inc: bson.M{"access_level": 1}})
+	expectError(t, err, cerrors.ErrForbiddenLevel)
+
+	// 级别字段以操作者的级别检查
+	levels := newRepo(collections.WithLevelFields("qty"))
+	idLevel := create(t, levels, staff, &item{Name: "level"})
+	err = levels.Update(as(staff), merchantA, idLevel, &item{Name: "level", Qty: int64(collections.LevelAdmin)})
+	expectError(t, err, cerrors.ErrForbiddenLevel)
+}
+
+func TestCreatedBy(t *testing.T) {
+	repo := newRepo()
+	m := &item{Name: "a"}
+	m.AccountID = "customer-1"
+	m.CreatedBy = "forged"
+	id := create(t, repo, merchantA, m)
+
+	found, err := repo.GetOne(as(merchantA), merchantA, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.CreatedBy != merchantA.AccountID || found.AccountID != "customer-1" {
+		t.Fatalf("expected created_by %s and account_id customer-1, got %s %s", merchantA.AccountID, found.CreatedBy, found.AccountID)
+	}
+	found.CreatedBy, found.AccountID = "other", "other"
+	if err := repo.Update(as(merchantA), merchantA, id, found); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ = repo.GetOne(as(merchantA), merchantA, id); found.CreatedBy != merchantA.AccountID || found.AccountID != "customer-1" {
+		t.Fatal("created_by and account_id should not be updated")
+	}
+}
+
+func TestFullUpdateKeepsAccessLevel(t *testing.T) {
+	repo := newRepo()
+	manager := merchantA
+	manager.AccessLevel = collections.LevelManager
+	id := create(t, repo, manager, &item{Name: "a"})
+	level := func() collections.AccessLevel {
+		t.Helper()
+		found, err := repo.GetOne(as(merchantA), merchantA, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return found.AccessLevel
+	}
+
+	if err := repo.Update(as(manager), manager, id, &item{Name: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdateIfVersion(as(manager), manager, id, 2, &item{Name: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	found, _ := repo.GetOne(as(merchantA), merchantA, id)
+	found.AccessLevel = collections.LevelPublic
+	expectError(t, repo.UpdateByID(context.Background(), found.ID, found), cerrors.ErrForbiddenLevel)
+	staff := merchantA
+	staff.AccessLevel = collections.LevelStaff
+	expectError(t, repo.UpdateByID(as(staff), found.ID, found), cerrors.ErrNotFound)
+	if err := repo.UpdateByID(as(manager), found.ID, found); err != nil {
+		t.Fatal(err)
+	}
+	if got := level(); got != collections.LevelManager {
+		t.Fatalf("full updates should keep the access level, got %s", got)
+	}
+
+	// 访问级别只能通过 Patch 修改
+	err := repo.Patch(as(manager), manager, id, collections.Patch{Set: bson.M{"access_level": collections.LevelStaff}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := level(); got != collections.LevelStaff {
+		t.Fatalf("expected staff, got %s", got)
+	}
+}
+
+func TestUpdateReplacesRecord(t *testing.T) {
+	repo := newRepo()
+	id := create(t, repo, merchantA, &item{Name: "a", Qty: 3, Note: "n"})
+
+	// 未设置的字段被置为零值，omitempty 的空字段保持不变
+	if err := repo.Update(as(merchantA), merchantA, id, &item{Name: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	found, err := repo.GetOne(as(merchantA), merchantA, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Name != "b" || found.Qty != 0 || found.Note != "n" || found.CreatedBy != merchantA.AccountID {
+		t.Fatalf("update should replace the record except immutable fields, got %+v", found)
+	}
+}
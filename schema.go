@@ -0,0 +1,283 @@
+package collections
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cerrors "github.com/r2day/collections/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// fieldSchema 模型中字段的结构
+// 根据 bson 标签解析，用于检查局部更新的字段以及取值的类型
+type fieldSchema struct {
+	// 字段类型，指针类型为指向的类型
+	typ reflect.Type
+	// 子文档的字段，只有结构体有效
+	fields map[string]*fieldSchema
+	// 数组以及 map 的元素
+	elem *fieldSchema
+}
+
+// schemaCache 各模型的字段结构
+var schemaCache sync.Map
+
+// schemaOf 返回模型的字段结构
+func schemaOf(t reflect.Type) *fieldSchema {
+	if s, ok := schemaCache.Load(t); ok {
+		return s.(*fieldSchema)
+	}
+	s := buildSchema(t, make(map[reflect.Type]*fieldSchema))
+	schemaCache.Store(t, s)
+	return s
+}
+
+// buildSchema 解析类型的字段结构
+// seen 用于处理递归的类型
+func buildSchema(t reflect.Type, seen map[reflect.Type]*fieldSchema) *fieldSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if s, ok := seen[t]; ok {
+		return s
+	}
+	// bson.D、bson.M 等可以保存任意内容
+	if t.Kind() == reflect.Interface || (isDriverType(t) && (t.Kind() == reflect.Slice || t.Kind() == reflect.Map)) {
+		return &fieldSchema{typ: anyType}
+	}
+	s := &fieldSchema{typ: t}
+	seen[t] = s
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		// []byte 以及 ObjectID 保存为单个值
+		if t.Elem().Kind() != reflect.Uint8 {
+			s.elem = buildSchema(t.Elem(), seen)
+		}
+	case reflect.Struct:
+		if isScalarStruct(t) {
+			return s
+		}
+		s.fields = make(map[string]*fieldSchema)
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, inline := bsonName(f)
+			if name == "-" {
+				continue
+			}
+			child := buildSchema(f.Type, seen)
+			if inline {
+				for key, field := range child.fields {
+					s.fields[key] = field
+				}
+				continue
+			}
+			s.fields[name] = child
+		}
+	}
+	return s
+}
+
+// anyType interface{} 的类型
+var anyType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// isDriverType 判断是否是 mongo 驱动中定义的类型
+func isDriverType(t reflect.Type) bool {
+	return strings.HasPrefix(t.PkgPath(), "go.mongodb.org/mongo-driver/")
+}
+
+// isScalarStruct 判断是否是保存为单个值的结构体，例如 time.Time
+func isScalarStruct(t reflect.Type) bool {
+	return t == reflect.TypeOf(time.Time{}) || isDriverType(t)
+}
+
+// bsonName 返回字段在 bson 中的名称以及是否内嵌
+// 与 mongo 驱动的规则一致，没有标签时为小写的字段名称
+func bsonName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("bson")
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	inline := false
+	for _, opt := range parts[1:] {
+		if opt == "inline" {
+			inline = true
+		}
+	}
+	if name == "" {
+		name = strings.ToLower(f.Name)
+	}
+	return name, inline
+}
+
+// isAny 判断字段是否可以保存任意内容，例如 interface{} 以及 bson.D
+func (s *fieldSchema) isAny() bool {
+	return s.typ == anyType
+}
+
+// lookup 返回路径对应的字段
+// 数组使用下标访问元素，map 的任意键都可以访问
+func (s *fieldSchema) lookup(path []string) (*fieldSchema, bool) {
+	if len(path) == 0 {
+		return s, true
+	}
+	if s.isAny() {
+		return s, true
+	}
+	switch s.typ.Kind() {
+	case reflect.Struct:
+		child, ok := s.fields[path[0]]
+		if !ok {
+			return nil, false
+		}
+		return child.lookup(path[1:])
+	case reflect.Slice, reflect.Array:
+		if _, err := strconv.Atoi(path[0]); err != nil || s.elem == nil {
+			return nil, false
+		}
+		return s.elem.lookup(path[1:])
+	case reflect.Map:
+		if s.elem == nil {
+			return nil, false
+		}
+		return s.elem.lookup(path[1:])
+	}
+	return nil, false
+}
+
+// convert 将取值转换为字段的类型
+// 例如 JSON 中的数字转换为整数；无法转换或者子文档、数组的类型不一致时返回 false
+func (s *fieldSchema) convert(val interface{}) (interface{}, bool) {
+	if val == nil || s.isAny() {
+		return val, true
+	}
+	t, data, err := bson.MarshalValue(val)
+	if err != nil {
+		return nil, false
+	}
+	switch s.typ.Kind() {
+	case reflect.Struct:
+		if !isScalarStruct(s.typ) && t != bsontype.EmbeddedDocument {
+			return nil, false
+		}
+	case reflect.Slice, reflect.Array:
+		if s.elem != nil && t != bsontype.Array {
+			return nil, false
+		}
+	case reflect.Map:
+		if t != bsontype.EmbeddedDocument {
+			return nil, false
+		}
+	}
+	v := reflect.New(s.typ)
+	if err := (bson.RawValue{Type: t, Value: data}).Unmarshal(v.Interface()); err != nil {
+		return nil, false
+	}
+	return v.Elem().Interface(), true
+}
+
+// isNumber 判断字段是否可以累加
+func (s *fieldSchema) isNumber() bool {
+	switch s.typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// schema 返回模型的字段结构
+func (r *Repository[T, PT]) schema() *fieldSchema {
+	return schemaOf(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// resolvePatch 根据模型的字段检查局部更新
+// 不允许更新模型中不存在的字段，Set 以及 Push 的取值转换为字段的类型
+// Inc 只能用于数字以及金额字段，Push 和 Pull 只能用于数组字段
+func (r *Repository[T, PT]) resolvePatch(patch Patch) (Patch, error) {
+	schema := r.schema()
+	field := func(key string) (*fieldSchema, error) {
+		f, ok := schema.lookup(strings.Split(key, "."))
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown field %s", cerrors.ErrValidation, key)
+		}
+		return f, nil
+	}
+	mismatch := func(key string, val interface{}) error {
+		return fmt.Errorf("%w: field %s does not accept %T", cerrors.ErrValidation, key, val)
+	}
+
+	resolved := patch
+	if len(patch.Set) > 0 {
+		resolved.Set = make(bson.M, len(patch.Set))
+	}
+	for key, val := range patch.Set {
+		f, err := field(key)
+		if err != nil {
+			return patch, err
+		}
+		// 已经计算好的密码哈希值保持原样
+		if _, ok := val.(PasswordHash); ok && f.typ.Kind() == reflect.String {
+			resolved.Set[key] = val
+			continue
+		}
+		v, ok := f.convert(val)
+		if !ok {
+			return patch, mismatch(key, val)
+		}
+		resolved.Set[key] = v
+	}
+	for _, key := range patch.Unset {
+		if _, err := field(key); err != nil {
+			return patch, err
+		}
+	}
+	for key, val := range patch.Inc {
+		f, err := field(key)
+		if err != nil {
+			return patch, err
+		}
+		_, isMoney := val.(Money)
+		if !f.isAny() && (isMoney != (f.typ == reflect.TypeOf(Money{})) || (!isMoney && !f.isNumber())) {
+			return patch, mismatch(key, val)
+		}
+	}
+	if len(patch.Push) > 0 {
+		resolved.Push = make(bson.M, len(patch.Push))
+	}
+	for key, val := range patch.Push {
+		f, err := field(key)
+		if err != nil {
+			return patch, err
+		}
+		if f.isAny() {
+			resolved.Push[key] = val
+			continue
+		}
+		if (f.typ.Kind() != reflect.Slice && f.typ.Kind() != reflect.Array) || f.elem == nil {
+			return patch, mismatch(key, val)
+		}
+		v, ok := f.elem.convert(val)
+		if !ok {
+			return patch, mismatch(key, val)
+		}
+		resolved.Push[key] = v
+	}
+	for key, val := range patch.Pull {
+		f, err := field(key)
+		if err != nil {
+			return patch, err
+		}
+		if !f.isAny() && ((f.typ.Kind() != reflect.Slice && f.typ.Kind() != reflect.Array) || f.elem == nil) {
+			return patch, mismatch(key, val)
+		}
+	}
+	return resolved, nil
+}
@@ -2,7 +2,6 @@ package supplier
 
 import (
 	"github.com/r2day/collections"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 const (
@@ -22,20 +21,7 @@ const (
 // 货主名称	供货商编码(必填）	供货商名称(必填)	供应商助记码	供货商类别编码(必填)	供货商类别名称(必填)	供货商联系人	电话(必填)	邮箱	地址	状态
 type Model struct {
 	// 基本的数据库模型字段，一般情况所有model都应该包含如下字段
-	// 创建时（用户上传的数据为空，所以默认可以不传该值)
-	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	// 商户号
-	MerchantID string `json:"merchant_id" bson:"merchant_id"`
-	// 创建者
-	AccountID string `json:"account_id" bson:"account_id"`
-	// 创建时间
-	CreatedAt string `json:"created_at" bson:"created_at"`
-	// 更新时间
-	UpdatedAt string `json:"updated_at" bson:"updated_at"`
-	// 状态
-	Status bool `json:"status"`
-	// 根据角色的最低级别写入
-	AccessLevel uint `json:"access_level" bson:"access_level"`
+	collections.BaseModel `bson:",inline"`
 	// 货主名称
 	CargoOwner string `json:"cargo_owner" bson:"cargo_owner"`
 	// 供货商编码(必填）
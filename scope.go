@@ -0,0 +1,60 @@
+package collections
+
+import (
+	cerrors "github.com/r2day/collections/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Scope 数据访问范围
+// 以商户id为基本命名空间
+// 并且只能访问小于等于自己级别的数据
+type Scope struct {
+	// 商户号
+	MerchantID string
+	// 访问者的级别
+	AccessLevel AccessLevel
+	// 访问者的账号id
+	// 用于记录删除者等操作信息
+	AccountID string
+}
+
+// Validate 检查访问范围是否有效
+// 未指定商户号时不允许访问任何数据
+func (s Scope) Validate() error {
+	if s.MerchantID == "" {
+		return cerrors.ErrForbiddenTenant
+	}
+	return nil
+}
+
+// Filter 返回访问范围对应的过滤条件
+func (s Scope) Filter() bson.D {
+	return bson.D{
+		{Key: "merchant_id", Value: s.MerchantID},
+		{Key: "access_level", Value: bson.D{{Key: "$lte", Value: s.AccessLevel}}},
+	}
+}
+
+// filterByID 返回访问范围内指定id的过滤条件
+func (s Scope) filterByID(id string) (bson.D, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	objID, err := ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	return append(bson.D{{Key: "_id", Value: objID}}, s.Filter()...), nil
+}
+
+// filterByIDs 返回访问范围内指定id列表的过滤条件
+func (s Scope) filterByIDs(ids []string) (bson.D, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	objIDs, err := ObjectIDsFromHex(ids)
+	if err != nil {
+		return nil, err
+	}
+	return append(bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: objIDs}}}}, s.Filter()...), nil
+}
@@ -0,0 +1,197 @@
+package collections
+
+import (
+	"context"
+	"time"
+
+	cerrors "github.com/r2day/collections/errors"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// deletedAtField 删除时间字段
+	deletedAtField = "deleted_at"
+	// deletedByField 删除者字段
+	deletedByField = "deleted_by"
+)
+
+// WithSoftDelete 开启软删除
+// 删除时只记录删除时间和删除者，默认查询不返回已删除的记录
+// retention 为已删除记录的保留时间，Purge 会物理删除超过保留时间的记录，0 表示永久保留
+func WithSoftDelete(retention time.Duration) Option {
+	return func(c *Config) {
+		c.SoftDelete = true
+		c.Retention = retention
+	}
+}
+
+// IncludeDeleted 列表以及详情中包含已删除的记录
+func IncludeDeleted() ListOption {
+	return func(o *ListOptions) {
+		o.Deleted = deletedInclude
+	}
+}
+
+// OnlyDeleted 列表以及详情只返回已删除的记录（回收站）
+func OnlyDeleted() ListOption {
+	return func(o *ListOptions) {
+		o.Deleted = deletedOnly
+	}
+}
+
+// deletedMode 已删除记录的查询方式
+type deletedMode int
+
+const (
+	// deletedExclude 不返回已删除的记录
+	deletedExclude deletedMode = iota
+	// deletedInclude 同时返回已删除的记录
+	deletedInclude
+	// deletedOnly 只返回已删除的记录
+	deletedOnly
+)
+
+// SoftDelete 是否开启了软删除
+func (r *Repository[T, PT]) SoftDelete() bool {
+	return r.conf.SoftDelete
+}
+
+// deletedFilter 返回已删除记录的过滤条件
+// 未开启软删除时不需要过滤
+func (r *Repository[T, PT]) deletedFilter(mode deletedMode) bson.D {
+	if !r.conf.SoftDelete {
+		return nil
+	}
+	switch mode {
+	case deletedInclude:
+		return nil
+	case deletedOnly:
+		return bson.D{{Key: deletedAtField, Value: bson.D{{Key: "$ne", Value: nil}}}}
+	}
+	return bson.D{{Key: deletedAtField, Value: nil}}
+}
+
+// filterByID 返回访问范围内未删除的指定id的过滤条件
+func (r *Repository[T, PT]) filterByID(scope Scope, id string) (bson.D, error) {
+	filter, err := scope.filterByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return append(filter, r.deletedFilter(deletedExclude)...), nil
+}
+
+// filterByIDs 返回访问范围内未删除的指定id列表的过滤条件
+func (r *Repository[T, PT]) filterByIDs(scope Scope, ids []string) (bson.D, error) {
+	filter, err := scope.filterByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	return append(filter, r.deletedFilter(deletedExclude)...), nil
+}
+
+// deleteUpdate 返回软删除的更新语句
+func deleteUpdate(scope Scope) bson.D {
+	deletedAt := now()
+	return bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: deletedAtField, Value: deletedAt},
+			{Key: deletedByField, Value: scope.AccountID},
+			{Key: "updated_at", Value: deletedAt},
+		}},
+		{Key: "$inc", Value: bson.D{{Key: versionField, Value: 1}}},
+	}
+}
+
+// deleteOne 删除一条记录
+// 开启软删除时只标记删除，返回被删除的记录数
+func (r *Repository[T, PT]) deleteOne(ctx context.Context, scope Scope, filter bson.D) (int64, error) {
+	if !r.conf.SoftDelete {
+		result, err := r.collection().DeleteOne(ctx, filter)
+		if err != nil {
+			return 0, err
+		}
+		return result.DeletedCount, nil
+	}
+	result, err := r.collection().UpdateOne(ctx, filter, deleteUpdate(scope))
+	if err != nil {
+		return 0, err
+	}
+	return result.MatchedCount, nil
+}
+
+// deleteMany 删除所有满足条件的记录
+// 开启软删除时只标记删除，返回被删除的记录数
+func (r *Repository[T, PT]) deleteMany(ctx context.Context, scope Scope, filter bson.D) (int64, error) {
+	if !r.conf.SoftDelete {
+		result, err := r.collection().DeleteMany(ctx, filter)
+		if err != nil {
+			return 0, err
+		}
+		return result.DeletedCount, nil
+	}
+	result, err := r.collection().UpdateMany(ctx, filter, deleteUpdate(scope))
+	if err != nil {
+		return 0, err
+	}
+	return result.MatchedCount, nil
+}
+
+// Restore 恢复已删除的记录
+// 只能恢复访问范围内的记录，记录不存在或者未被删除时返回 ErrNotFound
+func (r *Repository[T, PT]) Restore(ctx context.Context, scope Scope, id string) error {
+	logCtx := log.WithField("id", id).WithField("merchantID", scope.MerchantID)
+	scope, err := actorScope(ctx, scope)
+	if err != nil {
+		logCtx.Error(err)
+		return err
+	}
+	filter, err := scope.filterByID(id)
+	if err != nil {
+		logCtx.Error(err)
+		return err
+	}
+	if !r.conf.SoftDelete {
+		logCtx.Warning("soft delete is disabled")
+		return cerrors.NotFound(id)
+	}
+	filter = append(filter, r.deletedFilter(deletedOnly)...)
+
+	update := bson.D{
+		{Key: "$unset", Value: bson.D{{Key: deletedAtField, Value: ""}, {Key: deletedByField, Value: ""}}},
+		{Key: "$set", Value: bson.D{{Key: "updated_at", Value: now()}}},
+		{Key: "$inc", Value: bson.D{{Key: versionField, Value: 1}}},
+	}
+	result, err := r.collection().UpdateOne(ctx, filter, update)
+	if err != nil {
+		logCtx.Error(err)
+		return r.duplicateError(err)
+	}
+	if result.MatchedCount < 1 {
+		logCtx.Warning("no matched record")
+		return cerrors.NotFound(id)
+	}
+	return nil
+}
+
+// Purge 物理删除访问范围内超过保留时间的已删除记录
+// 一般由定时任务按商户调用；未开启软删除或者永久保留时不删除任何记录
+func (r *Repository[T, PT]) Purge(ctx context.Context, scope Scope) (int64, error) {
+	logCtx := log.WithField("collection", r.conf.CollectionName).WithField("merchantID", scope.MerchantID)
+	scope, err := actorScope(ctx, scope)
+	if err != nil {
+		logCtx.Error(err)
+		return 0, err
+	}
+	if !r.conf.SoftDelete || r.conf.Retention <= 0 {
+		return 0, nil
+	}
+	cutoff := now().Add(-r.conf.Retention)
+	filter := append(scope.Filter(), bson.E{Key: deletedAtField, Value: bson.D{{Key: "$ne", Value: nil}, {Key: "$lt", Value: cutoff}}})
+	result, err := r.collection().DeleteMany(ctx, filter)
+	if err != nil {
+		logCtx.Error(err)
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
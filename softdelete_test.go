@@ -0,0 +1,84 @@
+package collections_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/r2day/collections"
+	cerrors "github.com/r2day/collections/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSoftDelete(t *testing.T) {
+	repo := newRepo(collections.WithSoftDelete(time.Hour))
+	ctx := context.Background()
+	id := create(t, repo, merchantA, &item{Name: "a"})
+	create(t, repo, merchantA, &item{Name: "b"})
+
+	if err := repo.Delete(as(merchantA), merchantA, id); err != nil {
+		t.Fatal(err)
+	}
+	expectError(t, repo.Delete(as(merchantA), merchantA, id), cerrors.ErrNotFound)
+	if _, err := repo.GetOne(as(merchantA), merchantA, id); !errors.Is(err, cerrors.ErrNotFound) {
+		t.Fatalf("deleted record should be hidden, got %v", err)
+	}
+	if found, err := repo.GetOne(as(merchantA), merchantA, id, collections.IncludeDeleted()); err != nil || found.DeletedAt == nil {
+		t.Fatalf("deleted record should be returned with IncludeDeleted, got %+v %v", found, err)
+	}
+	if _, err := repo.GetOne(as(merchantB), merchantB, id, collections.IncludeDeleted()); !errors.Is(err, cerrors.ErrNotFound) {
+		t.Fatalf("deleted record of other merchants should be hidden, got %v", err)
+	}
+	trash, _, err := repo.GetList(as(merchantA), merchantA, params(nil), collections.OnlyDeleted())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 1 || trash[0].DeletedBy != merchantA.AccountID || trash[0].DeletedAt == nil {
+		t.Fatalf("unexpected trash %+v", trash)
+	}
+	all, _, err := repo.GetList(as(merchantA), merchantA, params(nil), collections.IncludeDeleted())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("expected 2 records including deleted, got %d", len(all))
+	}
+
+	if err := repo.Restore(as(merchantA), merchantA, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetOne(as(merchantA), merchantA, id); err != nil {
+		t.Fatalf("restored record should be visible, got %v", err)
+	}
+	if _, err := repo.GetOne(as(merchantA), merchantA, id, collections.OnlyDeleted()); !errors.Is(err, cerrors.ErrNotFound) {
+		t.Fatalf("restored record should not be in the trash, got %v", err)
+	}
+
+	// 只清理访问范围内超过保留时间的记录
+	expired := time.Now().Add(-2 * time.Hour)
+	coll := repo.Store().Collection(repo.CollectionName())
+	for _, merchantID := range []string{merchantA.MerchantID, merchantB.MerchantID} {
+		_, err := coll.InsertOne(ctx, bson.D{
+			{Key: "merchant_id", Value: merchantID},
+			{Key: "access_level", Value: collections.LevelPublic},
+			{Key: "name", Value: "expired"},
+			{Key: "deleted_at", Value: expired},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = repo.Purge(ctx, merchantA)
+	expectError(t, err, cerrors.ErrForbiddenLevel)
+	purged, err := repo.Purge(as(merchantA), merchantA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 1 {
+		t.Fatalf("expected 1 purged record, got %d", purged)
+	}
+	if count, _ := coll.CountDocuments(ctx, bson.D{{Key: "name", Value: "expired"}}); count != 1 {
+		t.Fatalf("records of other merchants should be kept, got %d", count)
+	}
+}
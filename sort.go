@@ -0,0 +1,130 @@
+package collections
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	cerrors "github.com/r2day/collections/errors"
+	"github.com/r2day/rest"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// idField 主键字段
+	idField = "_id"
+)
+
+// defaultSortableFields 所有模型都可以排序的字段
+var defaultSortableFields = []string{idField, "created_at", "updated_at", "status"}
+
+// SortField 排序字段
+type SortField struct {
+	// 字段名称，id 等同于 _id
+	Key string
+	// 是否倒序
+	Desc bool
+}
+
+// SortSpec 排序规则
+// 按顺序依次比较，前面的字段相同时才比较后面的字段
+type SortSpec []SortField
+
+// ParseSort 解析 react-admin 的排序参数
+// 支持 ["title","ASC"] 以及 [["title","ASC"],["id","DESC"]]
+func ParseSort(value string) (SortSpec, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	pairs := make([][]string, 0)
+	if err := json.Unmarshal([]byte(value), &pairs); err != nil {
+		pair := make([]string, 0)
+		if err := json.Unmarshal([]byte(value), &pair); err != nil {
+			return nil, fmt.Errorf("%w: invalid sort %s", cerrors.ErrValidation, value)
+		}
+		pairs = [][]string{pair}
+	}
+
+	spec := make(SortSpec, 0, len(pairs))
+	for _, pair := range pairs {
+		if len(pair) == 0 || len(pair) > 2 || pair[0] == "" {
+			return nil, fmt.Errorf("%w: invalid sort %s", cerrors.ErrValidation, value)
+		}
+		field := SortField{Key: pair[0]}
+		if len(pair) == 2 {
+			switch strings.ToUpper(pair[1]) {
+			case "ASC":
+			case "DESC":
+				field.Desc = true
+			default:
+				return nil, fmt.Errorf("%w: invalid sort order %s", cerrors.ErrValidation, pair[1])
+			}
+		}
+		spec = append(spec, field)
+	}
+	return spec, nil
+}
+
+// SortFromParams 返回 url 参数中的排序规则
+// rest.AES 为正序，rest.DESC 为倒序
+func SortFromParams(urlParams *rest.UrlParams) SortSpec {
+	if urlParams == nil || urlParams.Sort.Key == "" {
+		return nil
+	}
+	return SortSpec{{Key: urlParams.Sort.Key, Desc: urlParams.Sort.SortType == rest.DESC}}
+}
+
+// normalize 返回数据库中的排序规则
+// 统一 id 字段名称，并且以 _id 作为最后的排序字段
+// 保证排序结果稳定，分页时不会出现重复或者遗漏的记录
+func (s SortSpec) normalize() SortSpec {
+	normalized := make(SortSpec, 0, len(s)+1)
+	hasID := false
+	for _, f := range s {
+		if f.Key == "id" {
+			f.Key = idField
+		}
+		if f.Key == idField {
+			hasID = true
+		}
+		normalized = append(normalized, f)
+	}
+	if !hasID {
+		desc := false
+		if len(normalized) > 0 {
+			desc = normalized[len(normalized)-1].Desc
+		}
+		normalized = append(normalized, SortField{Key: idField, Desc: desc})
+	}
+	return normalized
+}
+
+// resolveSort 检查并返回数据库中的排序规则
+// 只允许对默认字段以及 sortable 中的字段排序，fields 中的金额字段按照最小货币单位排序
+func resolveSort(spec SortSpec, sortable []string, fields []FilterField) (SortSpec, error) {
+	normalized := spec.normalize()
+	for i, f := range normalized {
+		if !contains(defaultSortableFields, f.Key) && !contains(sortable, f.Key) {
+			return nil, fmt.Errorf("%w: field %s is not sortable", cerrors.ErrValidation, f.Key)
+		}
+		if field, ok := lookupFilterField(fields, f.Key); ok {
+			normalized[i].Key = field.path()
+		}
+	}
+	return normalized, nil
+}
+
+// BSON 返回 mongo 的排序语句
+func (s SortSpec) BSON() bson.D {
+	sort := make(bson.D, 0, len(s))
+	for _, f := range s {
+		direction := 1
+		if f.Desc {
+			direction = -1
+		}
+		sort = append(sort, bson.E{Key: f.Key, Value: direction})
+	}
+	return sort
+}
@@ -0,0 +1,41 @@
+package collections_test
+
+import (
+	"testing"
+
+	"github.com/r2day/collections"
+	cerrors "github.com/r2day/collections/errors"
+)
+
+func TestParseSort(t *testing.T) {
+	cases := []struct {
+		value string
+		want  collections.SortSpec
+	}{
+		{"", nil},
+		{`[]`, nil},
+		{`["title","ASC"]`, collections.SortSpec{{Key: "title"}}},
+		{`["title","desc"]`, collections.SortSpec{{Key: "title", Desc: true}}},
+		{`["title"]`, collections.SortSpec{{Key: "title"}}},
+		{`[["title","ASC"],["id","DESC"]]`, collections.SortSpec{{Key: "title"}, {Key: "id", Desc: true}}},
+	}
+	for _, c := range cases {
+		spec, err := collections.ParseSort(c.value)
+		if err != nil {
+			t.Fatalf("%s: %v", c.value, err)
+		}
+		if len(spec) != len(c.want) {
+			t.Fatalf("%s: expected %v, got %v", c.value, c.want, spec)
+		}
+		for i := range spec {
+			if spec[i] != c.want[i] {
+				t.Fatalf("%s: expected %v, got %v", c.value, c.want, spec)
+			}
+		}
+	}
+
+	for _, value := range []string{"title", `[""]`, `["title","UP"]`, `["a","ASC","x"]`, `[["a","ASC"],[]]`} {
+		_, err := collections.ParseSort(value)
+		expectError(t, err, cerrors.ErrValidation)
+	}
+}
@@ -0,0 +1,289 @@
+package collections
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	cerrors "github.com/r2day/collections/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FindOptions 查询选项
+type FindOptions struct {
+	// 排序方式
+	Sort bson.D
+	// 跳过的记录数
+	Skip int64
+	// 返回的记录数，0 表示不限制
+	Limit int64
+	// 返回的字段，为空时返回全部字段
+	Projection bson.D
+}
+
+// Collection 数据表需要支持的操作
+// 过滤条件与更新语句均使用 mongo 的语法
+// 未找到记录时 FindOne 返回 mongo.ErrNoDocuments
+type Collection interface {
+	// InsertOne 插入一条记录
+	InsertOne(ctx context.Context, doc interface{}) (*mongo.InsertOneResult, error)
+	// FindOne 查找一条记录
+	FindOne(ctx context.Context, filter bson.D) (bson.Raw, error)
+	// Find 查找记录
+	Find(ctx context.Context, filter bson.D, opts *FindOptions) ([]bson.Raw, error)
+	// CountDocuments 获取记录总数
+	CountDocuments(ctx context.Context, filter bson.D) (int64, error)
+	// UpdateOne 更新一条记录
+	UpdateOne(ctx context.Context, filter bson.D, update bson.D) (*mongo.UpdateResult, error)
+	// UpdateMany 更新所有满足条件的记录
+	UpdateMany(ctx context.Context, filter bson.D, update bson.D) (*mongo.UpdateResult, error)
+	// DeleteOne 删除一条记录
+	DeleteOne(ctx context.Context, filter bson.D) (*mongo.DeleteResult, error)
+	// DeleteMany 删除所有满足条件的记录
+	DeleteMany(ctx context.Context, filter bson.D) (*mongo.DeleteResult, error)
+	// Indexes 返回数据表的索引，不包括 _id 索引
+	Indexes(ctx context.Context) ([]Index, error)
+	// CreateIndex 创建索引
+	CreateIndex(ctx context.Context, index Index) error
+	// DropIndex 删除索引
+	DropIndex(ctx context.Context, name string) error
+}
+
+// Store 存储后端
+// 默认使用 mongo，测试时可以替换为内存实现
+type Store interface {
+	// Collection 返回数据表
+	Collection(name string) Collection
+}
+
+// defaultStore 默认的存储后端
+var defaultStore Store
+
+// SetDefaultStore 设置默认的存储后端
+// 一般在启动或者测试时调用，未设置时使用 DefaultDatabase
+func SetDefaultStore(store Store) {
+	defaultStore = store
+}
+
+// DefaultStore 返回默认的存储后端
+func DefaultStore() Store {
+	if defaultStore != nil {
+		return defaultStore
+	}
+	return NewMongoStore(DefaultDatabase())
+}
+
+// MongoStore mongo 存储后端
+type MongoStore struct {
+	database *mongo.Database
+}
+
+// mongoStores 各数据库的存储后端
+var mongoStores sync.Map
+
+// NewMongoStore 返回 mongo 存储后端
+// 同一个数据库返回相同的实例，便于变化通知等按存储后端区分数据
+func NewMongoStore(database *mongo.Database) *MongoStore {
+	store, _ := mongoStores.LoadOrStore(database, &MongoStore{database: database})
+	return store.(*MongoStore)
+}
+
+// SetSchema 设置数据表的 $jsonSchema
+// 数据表不存在时创建，已有的不满足规则的记录仍然可以更新
+func (s *MongoStore) SetSchema(ctx context.Context, name string, schema bson.D) error {
+	if s.database == nil {
+		return cerrors.ErrNoDatabase
+	}
+	validator := bson.D{{Key: "$jsonSchema", Value: schema}}
+	err := s.database.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: name},
+		{Key: "validator", Value: validator},
+		{Key: "validationLevel", Value: "moderate"},
+	}).Err()
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Name == "NamespaceNotFound" {
+		opts := options.CreateCollection().SetValidator(validator).SetValidationLevel("moderate")
+		return s.database.CreateCollection(ctx, name, opts)
+	}
+	return err
+}
+
+// Database 返回 mongo 数据库
+func (s *MongoStore) Database() *mongo.Database {
+	return s.database
+}
+
+// Collection 返回数据表
+// 没有设置数据库时所有操作返回 errors.ErrNoDatabase
+func (s *MongoStore) Collection(name string) Collection {
+	if s.database == nil {
+		return noDatabase{}
+	}
+	return &mongoCollection{coll: s.database.Collection(name)}
+}
+
+// mongoCollection mongo 数据表
+type mongoCollection struct {
+	coll *mongo.Collection
+}
+
+// InsertOne 插入一条记录
+func (c *mongoCollection) InsertOne(ctx context.Context, doc interface{}) (*mongo.InsertOneResult, error) {
+	return c.coll.InsertOne(ctx, doc)
+}
+
+// FindOne 查找一条记录
+func (c *mongoCollection) FindOne(ctx context.Context, filter bson.D) (bson.Raw, error) {
+	return c.coll.FindOne(ctx, filter).DecodeBytes()
+}
+
+// Find 查找记录
+func (c *mongoCollection) Find(ctx context.Context, filter bson.D, opts *FindOptions) ([]bson.Raw, error) {
+	opt := options.Find()
+	if opts != nil {
+		if len(opts.Sort) > 0 {
+			opt.SetSort(opts.Sort)
+		}
+		if len(opts.Projection) > 0 {
+			opt.SetProjection(opts.Projection)
+		}
+		opt.SetSkip(opts.Skip)
+		opt.SetLimit(opts.Limit)
+	}
+
+	cursor, err := c.coll.Find(ctx, filter, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]bson.Raw, 0)
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// CountDocuments 获取记录总数
+func (c *mongoCollection) CountDocuments(ctx context.Context, filter bson.D) (int64, error) {
+	return c.coll.CountDocuments(ctx, filter)
+}
+
+// UpdateOne 更新一条记录
+func (c *mongoCollection) UpdateOne(ctx context.Context, filter bson.D, update bson.D) (*mongo.UpdateResult, error) {
+	return c.coll.UpdateOne(ctx, filter, update)
+}
+
+// UpdateMany 更新所有满足条件的记录
+func (c *mongoCollection) UpdateMany(ctx context.Context, filter bson.D, update bson.D) (*mongo.UpdateResult, error) {
+	return c.coll.UpdateMany(ctx, filter, update)
+}
+
+// DeleteOne 删除一条记录
+func (c *mongoCollection) DeleteOne(ctx context.Context, filter bson.D) (*mongo.DeleteResult, error) {
+	return c.coll.DeleteOne(ctx, filter)
+}
+
+// DeleteMany 删除所有满足条件的记录
+func (c *mongoCollection) DeleteMany(ctx context.Context, filter bson.D) (*mongo.DeleteResult, error) {
+	return c.coll.DeleteMany(ctx, filter)
+}
+
+// Indexes 返回数据表的索引，不包括 _id 索引
+func (c *mongoCollection) Indexes(ctx context.Context) ([]Index, error) {
+	cursor, err := c.coll.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	specs := make([]struct {
+		Name   string `bson:"name"`
+		Key    bson.D `bson:"key"`
+		Unique bool   `bson:"unique"`
+	}, 0)
+	if err = cursor.All(ctx, &specs); err != nil {
+		return nil, err
+	}
+	indexes := make([]Index, 0, len(specs))
+	for _, spec := range specs {
+		if spec.Name == "_id_" {
+			continue
+		}
+		indexes = append(indexes, Index{Name: spec.Name, Keys: spec.Key, Unique: spec.Unique})
+	}
+	return indexes, nil
+}
+
+// CreateIndex 创建索引
+func (c *mongoCollection) CreateIndex(ctx context.Context, index Index) error {
+	opts := options.Index().SetName(index.Name)
+	if index.Unique {
+		opts.SetUnique(true)
+	}
+	_, err := c.coll.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: index.Keys, Options: opts})
+	return err
+}
+
+// DropIndex 删除索引
+func (c *mongoCollection) DropIndex(ctx context.Context, name string) error {
+	_, err := c.coll.Indexes().DropOne(ctx, name)
+	return err
+}
+
+// noDatabase 没有设置数据库时的数据表
+type noDatabase struct{}
+
+// InsertOne 插入一条记录
+func (noDatabase) InsertOne(ctx context.Context, doc interface{}) (*mongo.InsertOneResult, error) {
+	return nil, cerrors.ErrNoDatabase
+}
+
+// FindOne 查找一条记录
+func (noDatabase) FindOne(ctx context.Context, filter bson.D) (bson.Raw, error) {
+	return nil, cerrors.ErrNoDatabase
+}
+
+// Find 查找记录
+func (noDatabase) Find(ctx context.Context, filter bson.D, opts *FindOptions) ([]bson.Raw, error) {
+	return nil, cerrors.ErrNoDatabase
+}
+
+// CountDocuments 获取记录总数
+func (noDatabase) CountDocuments(ctx context.Context, filter bson.D) (int64, error) {
+	return 0, cerrors.ErrNoDatabase
+}
+
+// UpdateOne 更新一条记录
+func (noDatabase) UpdateOne(ctx context.Context, filter bson.D, update bson.D) (*mongo.UpdateResult, error) {
+	return nil, cerrors.ErrNoDatabase
+}
+
+// UpdateMany 更新所有满足条件的记录
+func (noDatabase) UpdateMany(ctx context.Context, filter bson.D, update bson.D) (*mongo.UpdateResult, error) {
+	return nil, cerrors.ErrNoDatabase
+}
+
+// DeleteOne 删除一条记录
+func (noDatabase) DeleteOne(ctx context.Context, filter bson.D) (*mongo.DeleteResult, error) {
+	return nil, cerrors.ErrNoDatabase
+}
+
+// DeleteMany 删除所有满足条件的记录
+func (noDatabase) DeleteMany(ctx context.Context, filter bson.D) (*mongo.DeleteResult, error) {
+	return nil, cerrors.ErrNoDatabase
+}
+
+// Indexes 返回数据表的索引
+func (noDatabase) Indexes(ctx context.Context) ([]Index, error) {
+	return nil, cerrors.ErrNoDatabase
+}
+
+// CreateIndex 创建索引
+func (noDatabase) CreateIndex(ctx context.Context, index Index) error {
+	return cerrors.ErrNoDatabase
+}
+
+// DropIndex 删除索引
+func (noDatabase) DropIndex(ctx context.Context, name string) error {
+	return cerrors.ErrNoDatabase
+}
@@ -0,0 +1,107 @@
+package collections
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimeLayout 时间的展示格式
+const TimeLayout = "2006-01-02 15:04:05"
+
+// timeLayouts 支持解析的时间格式
+var timeLayouts = []string{time.RFC3339Nano, TimeLayout, "2006-01-02 15:04", "2006-01-02", "2006/01/02 15:04:05", "2006/01/02"}
+
+var (
+	// locationMu 保护时区配置
+	locationMu sync.RWMutex
+	// defaultLocation 未配置时区的商户使用的时区
+	defaultLocation = time.Local
+	// merchantLocations 商户的时区
+	merchantLocations = make(map[string]*time.Location)
+	// systemLocation 升级之前服务器写入系统时间时使用的时区
+	systemLocation = time.Local
+)
+
+// SetSystemLocation 设置升级之前服务器写入系统时间时使用的时区
+// 创建时间、更新时间等由服务器按照本地时间生成，与商户的时区无关，迁移时按照该时区解析
+// loc 为空时使用服务器的本地时区
+func SetSystemLocation(loc *time.Location) {
+	locationMu.Lock()
+	defer locationMu.Unlock()
+	if loc == nil {
+		loc = time.Local
+	}
+	systemLocation = loc
+}
+
+// SystemLocation 返回升级之前服务器写入系统时间时使用的时区
+func SystemLocation() *time.Location {
+	locationMu.RLock()
+	defer locationMu.RUnlock()
+	return systemLocation
+}
+
+// SetDefaultLocation 设置默认时区
+// 未单独配置时区的商户使用该时区
+func SetDefaultLocation(loc *time.Location) {
+	locationMu.Lock()
+	defer locationMu.Unlock()
+	if loc == nil {
+		loc = time.Local
+	}
+	defaultLocation = loc
+}
+
+// SetMerchantLocation 设置商户的时区
+// loc 为空时使用默认时区
+func SetMerchantLocation(merchantID string, loc *time.Location) {
+	locationMu.Lock()
+	defer locationMu.Unlock()
+	if loc == nil {
+		delete(merchantLocations, merchantID)
+		return
+	}
+	merchantLocations[merchantID] = loc
+}
+
+// MerchantLocation 返回商户的时区
+func MerchantLocation(merchantID string) *time.Location {
+	locationMu.RLock()
+	defer locationMu.RUnlock()
+	if loc, ok := merchantLocations[merchantID]; ok {
+		return loc
+	}
+	return defaultLocation
+}
+
+// FormatTime 按照商户的时区展示时间
+// 零值返回空字符串
+func FormatTime(merchantID string, t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(MerchantLocation(merchantID)).Format(TimeLayout)
+}
+
+// ParseTime 解析时间
+// 支持 RFC3339 以及 2006-01-02 15:04:05、2006-01-02 等格式，不含时区的时间按照 loc 解析
+func ParseTime(val string, loc *time.Location) (time.Time, error) {
+	val = strings.TrimSpace(val)
+	if loc == nil {
+		loc = time.Local
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, val, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %s", val)
+}
+
+// now 返回当前时间
+// 数据库中的时间精确到毫秒
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
@@ -1,7 +1,9 @@
 package payflow
 
 import (
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+
+	"github.com/r2day/collections"
 )
 
 const (
@@ -21,22 +23,9 @@ const (
 // 交易时间	交易通道	交易来源	账务主体	交易信息	交易类型	交易子类型	交易金额	交易状态	交易号	订单号	备注	店铺组织编码
 type Model struct {
 	// 基本的数据库模型字段，一般情况所有model都应该包含如下字段
-	// 创建时（用户上传的数据为空，所以默认可以不传该值)
-	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	// 商户号
-	MerchantID string `json:"merchant_id" bson:"merchant_id"`
-	// 创建者
-	AccountID string `json:"account_id" bson:"account_id"`
-	// 创建时间
-	CreatedAt string `json:"created_at" bson:"created_at"`
-	// 更新时间
-	UpdatedAt string `json:"updated_at" bson:"updated_at"`
-	// 状态
-	Status bool `json:"status"`
-	// 根据角色的最低级别写入
-	AccessLevel uint `json:"access_level" bson:"access_level"`
+	collections.BaseModel `bson:",inline"`
 	// 交易时间
-	TradeTime string `json:"trade_time" bson:"trade_time"`
+	TradeTime time.Time `json:"trade_time" bson:"trade_time"`
 	// 交易通道
 	TradeChannel string `json:"trade_channel" bson:"trade_channel"`
 	// 交易来源
@@ -50,7 +39,7 @@ type Model struct {
 	// 交易子类型
 	TradeSubCategory string `json:"trade_sub_category" bson:"trade_sub_category"`
 	// 交易金额
-	Amount string `json:"amount" bson:"amount"`
+	Amount collections.Money `json:"amount" bson:"amount"`
 	// 交易状态
 	TradeStatus string `json:"trade_status" bson:"trade_status"`
 	// 订单号
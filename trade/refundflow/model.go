@@ -1,7 +1,9 @@
 package refundflow
 
 import (
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+
+	"github.com/r2day/collections"
 )
 
 const (
@@ -21,22 +23,9 @@ const (
 // 退款时间	交易通道	原交易号	原订单号	账务主体	原交易金额(元)	退款金额(元)	交易来源	交易类型	交易子类型	退款状态	备注	店铺组织编码
 type Model struct {
 	// 基本的数据库模型字段，一般情况所有model都应该包含如下字段
-	// 创建时（用户上传的数据为空，所以默认可以不传该值)
-	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	// 商户号
-	MerchantID string `json:"merchant_id" bson:"merchant_id"`
-	// 创建者
-	AccountID string `json:"account_id" bson:"account_id"`
-	// 创建时间
-	CreatedAt string `json:"created_at" bson:"created_at"`
-	// 更新时间
-	UpdatedAt string `json:"updated_at" bson:"updated_at"`
-	// 状态
-	Status bool `json:"status"`
-	// 根据角色的最低级别写入
-	AccessLevel uint `json:"access_level" bson:"access_level"`
+	collections.BaseModel `bson:",inline"`
 	// 退款时间
-	RefundTime string `json:"refund_time" bson:"refund_time"`
+	RefundTime time.Time `json:"refund_time" bson:"refund_time"`
 	// 交易通道
 	TradeChannel string `json:"trade_channel" bson:"trade_channel"`
 	// 原交易号
@@ -46,9 +35,9 @@ type Model struct {
 	// 账务主体
 	FinancialEntity string `json:"financial_entity" bson:"financial_entity"`
 	// 原交易金额(元)
-	OriginAmount float64 `json:"origin_amount" bson:"origin_amount"`
+	OriginAmount collections.Money `json:"origin_amount" bson:"origin_amount"`
 	// 原交易金额(元)
-	RefundAmount float64 `json:"refund_amount" bson:"refund_amount"`
+	RefundAmount collections.Money `json:"refund_amount" bson:"refund_amount"`
 	// 交易来源
 	TradeFrom string `json:"trade_from" bson:"trade_from"`
 	// 交易类型
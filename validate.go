@@ -0,0 +1,481 @@
+package collections
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	cerrors "github.com/r2day/collections/errors"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+const (
+	// phonePattern 手机号，支持 +86 前缀
+	phonePattern = `^(\+?86)?1[3-9]\d{9}$`
+	// emailPattern 邮箱
+	emailPattern = `^[^@\s]+@[^@\s]+\.[^@\s]+$`
+)
+
+// Rule 校验规则
+// 通过 Required、Range、Phone 等函数创建，Create、Update 以及 Patch 时执行
+// 除 Required 以外的规则在字段为空时不做检查
+type Rule struct {
+	// 字段，例如 user_info.phone
+	field string
+	// 规则名称
+	name string
+	// 自定义的错误描述
+	message string
+	// 取值范围
+	min, max *big.Rat
+	// 长度范围，maxLen 为 0 表示不限制
+	minLen, maxLen int
+	// 可选值
+	enum []string
+	// 正则表达式
+	pattern *regexp.Regexp
+	// 比较的操作符以及字段
+	op, other string
+}
+
+// Required 必填
+// 字段不存在、为 null、空字符串或者空数组时校验失败
+func Required(field string) Rule {
+	return Rule{field: field, name: "required"}
+}
+
+// Range 数字或者金额的取值范围，包含边界
+// 金额按照元等主币单位比较，例如 Range("refund_amount", 0, 10000)
+func Range(field string, min, max float64) Rule {
+	return Rule{field: field, name: "range", min: ratOf(min), max: ratOf(max)}
+}
+
+// Min 数字或者金额的最小值，包含边界
+func Min(field string, min float64) Rule {
+	return Rule{field: field, name: "range", min: ratOf(min)}
+}
+
+// Max 数字或者金额的最大值，包含边界
+func Max(field string, max float64) Rule {
+	return Rule{field: field, name: "range", max: ratOf(max)}
+}
+
+// Length 字符串或者数组的长度范围
+// max 为 0 表示不限制
+func Length(field string, min, max int) Rule {
+	return Rule{field: field, name: "length", minLen: min, maxLen: max}
+}
+
+// Enum 字符串的可选值
+func Enum(field string, values ...string) Rule {
+	return Rule{field: field, name: "enum", enum: values}
+}
+
+// Pattern 字符串需要满足正则表达式
+func Pattern(field string, expr string) Rule {
+	return Rule{field: field, name: "pattern", pattern: regexp.MustCompile(expr)}
+}
+
+// Phone 手机号
+func Phone(field string) Rule {
+	return Rule{field: field, name: "phone", pattern: regexp.MustCompile(phonePattern)}
+}
+
+// Email 邮箱
+func Email(field string) Rule {
+	return Rule{field: field, name: "email", pattern: regexp.MustCompile(emailPattern)}
+}
+
+// Compare 与另一个字段比较
+// op 为 eq、ne、gt、gte、lt、lte，例如 Compare("refund_amount", "lte", "origin_amount")
+// 支持数字、金额以及时间，任意一个字段为空时不做检查
+func Compare(field string, op string, other string) Rule {
+	switch op {
+	case opEq, opNe, opGt, opGte, opLt, opLte:
+	default:
+		panic(fmt.Sprintf("collections: unsupported compare operator %s", op))
+	}
+	return Rule{field: field, name: "compare", op: op, other: other}
+}
+
+// WithMessage 设置校验失败时的错误描述
+// 例如 "退款金额不能大于原交易金额"
+func (r Rule) WithMessage(message string) Rule {
+	r.message = message
+	return r
+}
+
+// WithRules 声明模型的校验规则
+func WithRules(rules ...Rule) Option {
+	return func(c *Config) {
+		c.Rules = append(c.Rules, rules...)
+	}
+}
+
+// WithJSONSchema 同时将校验规则安装为 mongo 的 $jsonSchema
+// 通过 InstallSchema 安装，Compare 等跨字段的规则只在程序中检查
+func WithJSONSchema() Option {
+	return func(c *Config) {
+		c.JSONSchema = true
+	}
+}
+
+// ratOf 将浮点数转换为十进制的有理数
+// 例如 0.1 转换为 1/10，而不是 0.1 的二进制近似值
+func ratOf(v float64) *big.Rat {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(v, 'f', -1, 64))
+	return r
+}
+
+// isEmpty 判断字段是否为空
+func isEmpty(rv bson.RawValue) bool {
+	switch rv.Type {
+	case bsontype.Null, bsontype.Undefined:
+		return true
+	case bsontype.String:
+		return rv.StringValue() == ""
+	case bsontype.Array:
+		values, _ := rv.Array().Values()
+		return len(values) == 0
+	}
+	return false
+}
+
+// numberOf 返回数字、金额或者时间的值
+// 金额按照主币单位，时间按照毫秒
+func numberOf(rv bson.RawValue) (*big.Rat, bool) {
+	switch rv.Type {
+	case bsontype.Int32:
+		return new(big.Rat).SetInt64(int64(rv.Int32())), true
+	case bsontype.Int64:
+		return new(big.Rat).SetInt64(rv.Int64()), true
+	case bsontype.Double:
+		r := new(big.Rat).SetFloat64(rv.Double())
+		return r, r != nil
+	case bsontype.Decimal128:
+		return new(big.Rat).SetString(rv.Decimal128().String())
+	case bsontype.DateTime:
+		return new(big.Rat).SetInt64(rv.DateTime()), true
+	case bsontype.EmbeddedDocument:
+		if _, err := rv.Document().LookupErr(moneyUnitsField); err != nil {
+			return nil, false
+		}
+		m := Money{}
+		if err := rv.Unmarshal(&m); err != nil {
+			return nil, false
+		}
+		return new(big.Rat).SetString(m.String())
+	}
+	return nil, false
+}
+
+// check 检查字段的值
+// 返回校验失败的原因，通过时返回空字符串
+func (r Rule) check(doc bson.Raw) string {
+	rv, err := doc.LookupErr(strings.Split(r.field, ".")...)
+	if err != nil || isEmpty(rv) {
+		if r.name == "required" {
+			return "is required"
+		}
+		return ""
+	}
+
+	switch r.name {
+	case "range":
+		n, ok := numberOf(rv)
+		if !ok {
+			return "must be a number"
+		}
+		if r.min != nil && n.Cmp(r.min) < 0 {
+			return "must be at least " + r.min.RatString()
+		}
+		if r.max != nil && n.Cmp(r.max) > 0 {
+			return "must be at most " + r.max.RatString()
+		}
+	case "length":
+		var length int
+		switch rv.Type {
+		case bsontype.String:
+			length = utf8.RuneCountInString(rv.StringValue())
+		case bsontype.Array:
+			values, _ := rv.Array().Values()
+			length = len(values)
+		default:
+			return "must be a string or an array"
+		}
+		if length < r.minLen {
+			return fmt.Sprintf("length must be at least %d", r.minLen)
+		}
+		if r.maxLen > 0 && length > r.maxLen {
+			return fmt.Sprintf("length must be at most %d", r.maxLen)
+		}
+	case "enum":
+		val, ok := rv.StringValueOK()
+		if !ok {
+			return "must be a string"
+		}
+		for _, e := range r.enum {
+			if val == e {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(r.enum, ", ")
+	case "pattern", "phone", "email":
+		val, ok := rv.StringValueOK()
+		if !ok {
+			return "must be a string"
+		}
+		if !r.pattern.MatchString(val) {
+			if r.name == "pattern" {
+				return "must match " + r.pattern.String()
+			}
+			return "is not a valid " + r.name
+		}
+	case "compare":
+		other, err := doc.LookupErr(strings.Split(r.other, ".")...)
+		if err != nil || isEmpty(other) {
+			return ""
+		}
+		a, ok := numberOf(rv)
+		b, ok2 := numberOf(other)
+		if !ok || !ok2 {
+			return "cannot be compared with " + r.other
+		}
+		c := a.Cmp(b)
+		passed := map[string]bool{opEq: c == 0, opNe: c != 0, opGt: c > 0, opGte: c >= 0, opLt: c < 0, opLte: c <= 0}
+		if !passed[r.op] {
+			return fmt.Sprintf("must be %s %s", r.op, r.other)
+		}
+	}
+	return ""
+}
+
+// validate 检查记录是否满足校验规则
+// 返回所有校验失败的字段
+func validate(doc bson.Raw, rules []Rule) error {
+	fields := make([]cerrors.FieldError, 0)
+	for _, rule := range rules {
+		reason := rule.check(doc)
+		if reason == "" {
+			continue
+		}
+		message := rule.message
+		if message == "" {
+			message = reason
+		}
+		fields = append(fields, cerrors.FieldError{Field: rule.field, Rule: rule.name, Message: message})
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return &cerrors.ValidationError{Fields: fields}
+}
+
+// Validate 检查记录是否满足模型的校验规则
+// 校验失败时返回 *errors.ValidationError，其中包含所有校验失败的字段
+func (r *Repository[T, PT]) Validate(m PT) error {
+	if len(r.conf.Rules) == 0 {
+		return nil
+	}
+	data, err := bson.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return validate(data, r.conf.Rules)
+}
+
+// covers 判断更新的字段是否包含规则的字段
+func covers(key string, field string) bool {
+	return key == field || strings.HasPrefix(field, key+".")
+}
+
+// checkPatch 检查局部更新的内容并返回转换为字段类型后的更新
+// 字段需要在模型中存在并且取值的类型一致，
+// 校验规则只检查 Set 和 Unset 涉及的字段，跨字段的规则只在两个字段都被设置时检查
+func (r *Repository[T, PT]) checkPatch(patch Patch) (Patch, error) {
+	if err := patch.Validate(); err != nil {
+		return patch, err
+	}
+	patch, err := r.resolvePatch(patch)
+	if err != nil {
+		return patch, err
+	}
+	if len(r.conf.Rules) == 0 {
+		return patch, nil
+	}
+	set := bson.M{}
+	for key, val := range patch.Set {
+		setPath(set, strings.Split(key, "."), val)
+	}
+	data, err := bson.Marshal(set)
+	if err != nil {
+		return patch, err
+	}
+	touched := func(field string) bool {
+		for key := range patch.Set {
+			if covers(key, field) {
+				return true
+			}
+		}
+		for _, key := range patch.Unset {
+			if covers(key, field) {
+				return true
+			}
+		}
+		return false
+	}
+	rules := make([]Rule, 0)
+	for _, rule := range r.conf.Rules {
+		if !touched(rule.field) || (rule.name == "compare" && !touched(rule.other)) {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return patch, validate(data, rules)
+}
+
+// setPath 按照路径设置嵌套文档中的值
+func setPath(doc bson.M, path []string, val interface{}) {
+	if len(path) == 1 {
+		doc[path[0]] = val
+		return
+	}
+	child, ok := doc[path[0]].(bson.M)
+	if !ok {
+		child = bson.M{}
+		doc[path[0]] = child
+	}
+	setPath(child, path[1:], val)
+}
+
+// schemaNode $jsonSchema 中的一个对象
+type schemaNode struct {
+	keywords bson.D
+	required []string
+	children map[string]*schemaNode
+	order    []string
+}
+
+// child 返回子节点，不存在时创建
+func (n *schemaNode) child(name string) *schemaNode {
+	if n.children == nil {
+		n.children = make(map[string]*schemaNode)
+	}
+	c, ok := n.children[name]
+	if !ok {
+		c = &schemaNode{}
+		n.children[name] = c
+		n.order = append(n.order, name)
+	}
+	return c
+}
+
+// lookup 返回路径对应的节点以及父节点
+func (n *schemaNode) lookup(path []string) (*schemaNode, *schemaNode) {
+	parent := n
+	for _, name := range path[:len(path)-1] {
+		parent = parent.child(name)
+	}
+	return parent.child(path[len(path)-1]), parent
+}
+
+// document 返回 $jsonSchema 文档
+func (n *schemaNode) document() bson.D {
+	doc := append(bson.D{}, n.keywords...)
+	if len(n.required) > 0 {
+		sort.Strings(n.required)
+		doc = append(doc, bson.E{Key: "required", Value: n.required})
+	}
+	if len(n.order) > 0 {
+		props := bson.D{}
+		for _, name := range n.order {
+			props = append(props, bson.E{Key: name, Value: n.children[name].document()})
+		}
+		doc = append(doc, bson.E{Key: "properties", Value: props})
+	}
+	return doc
+}
+
+// isMoneyField 判断是否是金额字段
+func (r *Repository[T, PT]) isMoneyField(field string) bool {
+	return contains(r.moneyFields(), field)
+}
+
+// JSONSchema 返回校验规则对应的 $jsonSchema
+// 空值总是允许，Compare 等跨字段的规则不会出现在其中
+func (r *Repository[T, PT]) JSONSchema() bson.D {
+	root := &schemaNode{keywords: bson.D{{Key: "bsonType", Value: "object"}}}
+	for _, rule := range r.conf.Rules {
+		path := strings.Split(rule.field, ".")
+		node, parent := root.lookup(path)
+		switch rule.name {
+		case "required":
+			if !contains(parent.required, path[len(path)-1]) {
+				parent.required = append(parent.required, path[len(path)-1])
+			}
+		case "range":
+			scale := big.NewRat(1, 1)
+			if r.isMoneyField(rule.field) {
+				// 金额比较最小货币单位
+				node = node.child(moneyUnitsField)
+				scale = new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(Money{}.exponent())), nil))
+			}
+			if rule.min != nil {
+				v, _ := new(big.Rat).Mul(rule.min, scale).Float64()
+				node.keywords = append(node.keywords, bson.E{Key: "minimum", Value: v})
+			}
+			if rule.max != nil {
+				v, _ := new(big.Rat).Mul(rule.max, scale).Float64()
+				node.keywords = append(node.keywords, bson.E{Key: "maximum", Value: v})
+			}
+		case "length":
+			if rule.minLen > 0 {
+				node.keywords = append(node.keywords, bson.E{Key: "minLength", Value: rule.minLen}, bson.E{Key: "minItems", Value: rule.minLen})
+			}
+			if rule.maxLen > 0 {
+				node.keywords = append(node.keywords, bson.E{Key: "maxLength", Value: rule.maxLen}, bson.E{Key: "maxItems", Value: rule.maxLen})
+			}
+		case "enum":
+			values := bson.A{nil, ""}
+			for _, e := range rule.enum {
+				values = append(values, e)
+			}
+			node.keywords = append(node.keywords, bson.E{Key: "enum", Value: values})
+		case "pattern", "phone", "email":
+			node.keywords = append(node.keywords, bson.E{Key: "pattern", Value: "^$|" + rule.pattern.String()})
+		}
+	}
+	return root.document()
+}
+
+// SchemaStore 支持 $jsonSchema 校验的存储后端
+type SchemaStore interface {
+	// SetSchema 设置数据表的 $jsonSchema
+	SetSchema(ctx context.Context, name string, schema bson.D) error
+}
+
+// InstallSchema 将校验规则安装为数据表的 $jsonSchema
+// 未开启 WithJSONSchema 或者存储后端不支持时不做任何操作，可以重复执行
+func (r *Repository[T, PT]) InstallSchema(ctx context.Context) error {
+	if !r.conf.JSONSchema {
+		return nil
+	}
+	logCtx := log.WithField("collection", r.conf.CollectionName)
+	store, ok := r.Store().(SchemaStore)
+	if !ok {
+		logCtx.Warning("store does not support json schema")
+		return nil
+	}
+	if err := store.SetSchema(ctx, r.conf.CollectionName, r.JSONSchema()); err != nil {
+		logCtx.Error(err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package collections_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/r2day/collections"
+	cerrors "github.com/r2day/collections/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+func TestValidation(t *testing.T) {
+	repo := newRepo(collections.WithRules(collections.Required("name"), collections.Range("qty", 0, 10)))
+	ctx := as(merchantA)
+
+	_, err := repo.Create(ctx, &item{Qty: 11})
+	verr := &cerrors.ValidationError{}
+	if !errors.As(err, &verr) || len(verr.Fields) != 2 {
+		t.Fatalf("expected two field errors, got %v", err)
+	}
+	id := create(t, repo, merchantA, &item{Name: "a", Qty: 1})
+	expectError(t, repo.Patch(as(merchantA), merchantA, id, collections.Patch{Set: bson.M{"qty": 20}}), cerrors.ErrValidation)
+	expectError(t, repo.Patch(as(merchantA), merchantA, id, collections.Patch{Unset: []string{"name"}}), cerrors.ErrValidation)
+}
+
+func TestJSONSchema(t *testing.T) {
+	repo := newRepo(
+		collections.WithMoneyFields("price"),
+		collections.WithRules(
+			collections.Required("name"),
+			collections.Range("qty", 0, 10),
+			collections.Range("price", 0, 12.5),
+			collections.Length("tags", 1, 3),
+			collections.Enum("note", "a", "b"),
+			collections.Compare("qty", "lte", "price"),
+		),
+	)
+	data, err := bson.Marshal(repo.JSONSchema())
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := bson.Raw(data)
+	lookup := func(path ...string) bson.RawValue {
+		t.Helper()
+		rv, err := schema.LookupErr(path...)
+		if err != nil {
+			t.Fatalf("%v: %v", path, err)
+		}
+		return rv
+	}
+
+	if lookup("bsonType").StringValue() != "object" {
+		t.Fatal("the root should be an object")
+	}
+	if required, _ := lookup("required").Array().Values(); len(required) != 1 || required[0].StringValue() != "name" {
+		t.Fatalf("unexpected required fields %v", required)
+	}
+	if lookup("properties", "qty", "minimum").Double() != 0 || lookup("properties", "qty", "maximum").Double() != 10 {
+		t.Fatal("unexpected qty range")
+	}
+	// 金额比较最小货币单位
+	if lookup("properties", "price", "properties", "units", "maximum").Double() != 1250 {
+		t.Fatal("money range should be in units")
+	}
+	if lookup("properties", "tags", "minItems").Int32() != 1 || lookup("properties", "tags", "maxLength").Int32() != 3 {
+		t.Fatal("unexpected tags length")
+	}
+	// 空值总是允许
+	if values, _ := lookup("properties", "note", "enum").Array().Values(); len(values) != 4 || values[0].Type != bsontype.Null || values[1].StringValue() != "" {
+		t.Fatalf("unexpected note enum %v", values)
+	}
+	if keys, _ := lookup("properties", "qty").Document().Elements(); len(keys) != 2 {
+		t.Fatalf("compare rules should not be in the schema, got %v", keys)
+	}
+}
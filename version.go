@@ -0,0 +1,114 @@
+package collections
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	cerrors "github.com/r2day/collections/errors"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// versionField 版本号字段
+	versionField = "version"
+)
+
+// ETag 返回记录版本对应的 ETag
+// 例如 "3"，可以直接写入响应头
+func (m *BaseModel) ETag() string {
+	return strconv.Quote(strconv.FormatInt(m.Version, 10))
+}
+
+// ParseETag 解析 If-Match 请求头中的版本号
+// 支持 "3" 以及 W/"3" 的形式
+func ParseETag(etag string) (int64, error) {
+	value := strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+	value = strings.Trim(value, `"`)
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("%w: invalid etag %s", cerrors.ErrValidation, etag)
+	}
+	return version, nil
+}
+
+// versionFilter 返回指定版本的过滤条件
+// 没有版本号的历史记录视为版本 0
+func versionFilter(version int64) bson.E {
+	if version == 0 {
+		return bson.E{Key: versionField, Value: bson.D{{Key: "$in", Value: bson.A{int64(0), nil}}}}
+	}
+	return bson.E{Key: versionField, Value: version}
+}
+
+// versionConflict 返回版本不一致时的错误
+// 记录存在时返回 ErrConflict，否则返回 ErrNotFound
+func (r *Repository[T, PT]) versionConflict(ctx context.Context, scope Scope, id string, version int64) error {
+	filter, err := r.filterByID(scope, id)
+	if err != nil {
+		return err
+	}
+	count, err := r.collection().CountDocuments(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return cerrors.NotFound(id)
+	}
+	return fmt.Errorf("%w: %s is not at version %d", cerrors.ErrConflict, id, version)
+}
+
+// UpdateIfVersion 版本号一致时整体更新，替换规则与 Update 相同
+// 记录已经被其他人修改时返回 ErrConflict，成功后 m 的版本号加 1
+func (r *Repository[T, PT]) UpdateIfVersion(ctx context.Context, scope Scope, id string, version int64, m PT) error {
+	logCtx := log.WithField("id", id).WithField("version", version)
+	scope, err := actorScope(ctx, scope)
+	if err != nil {
+		logCtx.Error(err)
+		return err
+	}
+	filter, err := r.filterByID(scope, id)
+	if err != nil {
+		logCtx.Error(err)
+		return err
+	}
+	if err := r.checkLevels(scope, m); err != nil {
+		logCtx.Error(err)
+		return err
+	}
+	base := m.GetBaseModel()
+	base.MerchantID = scope.MerchantID
+	err = r.updateOne(ctx, append(filter, versionFilter(version)), m)
+	if errors.Is(err, cerrors.ErrNotFound) {
+		err = r.versionConflict(ctx, scope, id, version)
+	}
+	if err != nil {
+		logCtx.Error(err)
+		return err
+	}
+	base.Version = version + 1
+	return nil
+}
+
+// PatchIfVersion 版本号一致时局部更新
+// 记录已经被其他人修改时返回 ErrConflict
+func (r *Repository[T, PT]) PatchIfVersion(ctx context.Context, scope Scope, id string, version int64, patch Patch) error {
+	logCtx := log.WithField("id", id).WithField("version", version)
+	scope, err := actorScope(ctx, scope)
+	if err != nil {
+		logCtx.Error(err)
+		return err
+	}
+	err = r.patchOne(ctx, scope, id, patch, versionFilter(version))
+	if errors.Is(err, cerrors.ErrNotFound) {
+		err = r.versionConflict(ctx, scope, id, version)
+	}
+	if err != nil {
+		logCtx.Error(err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package collections_test
+
+import (
+	"testing"
+
+	"github.com/r2day/collections"
+	cerrors "github.com/r2day/collections/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseETag(t *testing.T) {
+	for etag, want := range map[string]int64{`"3"`: 3, `W/"3"`: 3, " 0 ": 0, "12": 12} {
+		version, err := collections.ParseETag(etag)
+		if err != nil || version != want {
+			t.Fatalf("%s: expected %d, got %d %v", etag, want, version, err)
+		}
+	}
+	for _, etag := range []string{"", `"abc"`, `"-1"`, `W/`} {
+		_, err := collections.ParseETag(etag)
+		expectError(t, err, cerrors.ErrValidation)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	repo := newRepo()
+	id := create(t, repo, merchantA, &item{Name: "a"})
+
+	m, err := repo.GetOne(as(merchantA), merchantA, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != 1 {
+		t.Fatalf("new record should be at version 1, got %d", m.Version)
+	}
+	m.Name = "b"
+	if err := repo.UpdateIfVersion(as(merchantA), merchantA, id, 1, m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != 2 {
+		t.Fatalf("version should be bumped, got %d", m.Version)
+	}
+	m.Name = "c"
+	expectError(t, repo.UpdateIfVersion(as(merchantA), merchantA, id, 1, m), cerrors.ErrConflict)
+	expectError(t, repo.PatchIfVersion(as(merchantA), merchantA, id, 1, collections.Patch{Set: bson.M{"name": "c"}}), cerrors.ErrConflict)
+	if err := repo.PatchIfVersion(as(merchantA), merchantA, id, 2, collections.Patch{Set: bson.M{"name": "c"}}); err != nil {
+		t.Fatal(err)
+	}
+	expectError(t, repo.PatchIfVersion(as(merchantB), merchantB, id, 3, collections.Patch{Set: bson.M{"name": "d"}}), cerrors.ErrNotFound)
+}